@@ -3,9 +3,12 @@ package tencentcloud
 import (
 	"context"
 	"strings"
+	"sync"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -25,26 +28,94 @@ func newBackend(profile *clients.ClientProfile) *backend {
 		PathsSpecial: &logical.Paths{
 			SealWrapStorage: []string{
 				"config",
+				"config/account/",
+			},
+			// Leases are local to the cluster that issued them, and so is
+			// everything tracking them or feeding them.
+			LocalStorage: []string{
+				issuedPath,
+				leaseCountPath,
+				poolPath,
+				stsRevocationPath,
+				stsRevocationIdPath,
 			},
 		},
 		Paths: []*framework.Path{
 			pathConfig(b),
+			pathConfigAccount(b),
+			pathListConfigAccounts(b),
+			pathConfigCheck(b),
+			pathConfigLimits(b),
 			pathRole(b),
+			pathRolePreview(b),
+			pathListRoleVersions(b),
+			pathRoleVersion(b),
+			pathExportRoles(b),
+			pathImportRoles(b),
 			pathListRoles(b),
 			pathCreds(b),
+			pathListIssued(b),
+			pathIssued(b),
+			pathLookup(b),
+			pathCleanupUser(b),
 		},
 		Secrets: []*framework.Secret{
 			pathSecrets(b),
 		},
-		BackendType: logical.TypeLogical,
+		PeriodicFunc: b.periodicFunc,
+		Invalidate:   b.invalidate,
+		BackendType:  logical.TypeLogical,
 	}
 	b.profile = profile
+	b.credentialProviders = make(map[string]*clients.CachedProvider)
+	b.clients = make(map[string]*accountClients)
 	return b
 }
 
 type backend struct {
 	*framework.Backend
 	profile *clients.ClientProfile
+
+	stsRevocationLock sync.Mutex
+
+	// roleLock serializes writes of roles, so every write gets a version of
+	// its own and none is lost.
+	roleLock sync.Mutex
+
+	// poolLock guards claiming and removing members of the user pools.
+	poolLock sync.Mutex
+
+	leaseCountLock sync.Mutex
+
+	credentialProvidersLock sync.Mutex
+	credentialProviders     map[string]*clients.CachedProvider
+
+	// clients are keyed by account, "" being the credentials in config.
+	// clientsGeneration changes whenever a config is written or deleted.
+	clientsLock       sync.RWMutex
+	clients           map[string]*accountClients
+	clientsGeneration uint64
+}
+
+func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	// Only the active node of a cluster may write, even to local storage.
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby | consts.ReplicationDRSecondary) {
+		return nil
+	}
+	apiErrs := &multierror.Error{}
+	if err := b.tidySTSRevocations(ctx, req.Storage); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
+	}
+	if err := b.tidyPools(ctx, req.Storage); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
+	}
+	if err := b.tidyIssued(ctx, req.Storage); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
+	}
+	if err := b.recountLeases(ctx, req.Storage); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
+	}
+	return apiErrs.ErrorOrNil()
 }
 
 const backendHelp = `
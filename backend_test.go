@@ -1,23 +1,141 @@
 package tencentcloud
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/pluginidentityutil"
+	"github.com/hashicorp/vault/sdk/helper/pluginutil"
 	"github.com/hashicorp/vault/sdk/logical"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func setup() *httptest.Server {
+	// New access keys are rejected the first time they are used, as they are
+	// while CAM propagates them.
+	var newKeyUses int32
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		// All responses below are directly from AliCloud's documentation
 		// and none reflect real values.
+		// The CVM instance metadata service.
+		if strings.HasPrefix(r.URL.Path, "/latest/meta-data/cam/security-credentials/") {
+			if path.Base(r.URL.Path) != "vault-cvm" {
+				w.WriteHeader(404)
+				return
+			}
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "TmpSecretId": "AKIDcvmrole******WIQVMn1umNH58",
+			  "TmpSecretKey": "cvmrole*******52boxvp71yoh",
+			  "ExpiredTime": 4102444800,
+			  "Expiration": "2100-01-01T00:00:00Z",
+			  "Token": "cvmroletoken001",
+			  "Code": "Success"
+			}`))
+			return
+		}
+
 		action := r.Header.Get("X-TC-Action")
+		if action == "DeleteAccessKey" {
+			// The access key AKIDdeletedbyhand was removed outside of Vault.
+			body, _ := ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			if strings.Contains(string(body), "AKIDdeletedbyhand") {
+				w.WriteHeader(200)
+				w.Write([]byte(`{
+				  "Response": {
+					"Error": {
+					  "Code": "FailedOperation.Accesskey",
+					  "Message": "The access key does not exist."
+					},
+					"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+				  }
+				}`))
+				return
+			}
+		}
+		switch action {
+		case "DeleteAccessKey", "DetachUserPolicy", "DeleteUser":
+			// The user deleted-by-hand was removed outside of Vault.
+			body, _ := ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			if strings.Contains(string(body), "deleted-by-hand") || strings.Contains(string(body), "100000000404") {
+				w.WriteHeader(200)
+				w.Write([]byte(`{
+				  "Response": {
+					"Error": {
+					  "Code": "InvalidParameter.UserNotExist",
+					  "Message": "The user does not exist."
+					},
+					"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+				  }
+				}`))
+				return
+			}
+		case "ListPolicies":
+			if strings.Contains(r.Header.Get("Authorization"), "Credential=unauthorized/") {
+				w.WriteHeader(200)
+				w.Write([]byte(`{
+				  "Response": {
+					"Error": {
+					  "Code": "UnauthorizedOperation",
+					  "Message": "The principal is not authorized to call ListPolicies."
+					},
+					"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+				  }
+				}`))
+				return
+			}
+		case "UpdatePolicy":
+			// Policy 404 was deleted by hand.
+			body, _ := ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			if strings.Contains(string(body), `"PolicyId":404`) {
+				w.WriteHeader(200)
+				w.Write([]byte(`{
+				  "Response": {
+					"Error": {
+					  "Code": "InvalidParameter.PolicyIdNotExist",
+					  "Message": "The policy does not exist."
+					},
+					"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+				  }
+				}`))
+				return
+			}
+		case "DeletePolicy":
+			// Policy 1 is shared and must only ever be detached.
+			body, _ := ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			if strings.Contains(string(body), `"PolicyId":[1]`) {
+				w.WriteHeader(200)
+				w.Write([]byte(`{
+				  "Response": {
+					"Error": {
+					  "Code": "OperationDenied.PolicyInUse",
+					  "Message": "The shared policy must not be deleted."
+					},
+					"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+				  }
+				}`))
+				return
+			}
+		}
 		switch action {
 
 		case "AddUser":
@@ -67,6 +185,14 @@ func setup() *httptest.Server {
                 }
 			}`))
 
+		case "UpdatePolicy", "AttachRolePolicy", "DetachRolePolicy":
+			w.WriteHeader(200)
+			w.Write([]byte(`    {
+				"Response": {
+                   "RequestId": "1a21f666-d00e-4df8-92f7-7121f9012e43"
+                }
+			}`))
+
 		case "DetachUserPolicy":
 			w.WriteHeader(200)
 			w.Write([]byte(`    {
@@ -123,7 +249,215 @@ func setup() *httptest.Server {
                 }
 			}`))
 
+		case "GetUser":
+			body, _ := ioutil.ReadAll(r.Body)
+			if !strings.Contains(string(body), "test124") {
+				w.WriteHeader(200)
+				w.Write([]byte(`{
+				  "Response": {
+					"Error": {
+					  "Code": "InvalidParameter.UserNotExist",
+					  "Message": "The user does not exist."
+					},
+					"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+				  }
+				}`))
+				return
+			}
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"Uin": 100000546533,
+				"Name": "test124",
+				"Uid": 5648765,
+				"Remark": "",
+				"ConsoleLogin": 0,
+				"RequestId": "b46d2afe-6893-4529-bc96-2c82d9214957"
+			  }
+			}`))
+
+		case "ListUsers":
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"Data": [
+				  {
+					"Uin": 100000546533,
+					"Name": "test124",
+					"Uid": 5648765,
+					"CreateTime": "2020-03-03 18:00:26"
+				  },
+				  {
+					"Uin": 100000000777,
+					"Name": "admin",
+					"Uid": 777,
+					"CreateTime": "2020-03-03 18:00:26"
+				  }
+				],
+				"RequestId": "b46d2afe-6893-4529-bc96-2c82d9214957"
+			  }
+			}`))
+
+		case "ListAccessKeys":
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"AccessKeys": [
+				  {
+					"AccessKeyId": "ABBD8GFED7sSr33rSq9KK7h5ISSEoQrFXkmb",
+					"Status": "Active",
+					"CreateTime": "2020-03-03 18:00:26"
+				  }
+				],
+				"RequestId": "f8423e9b-a7da-488d-9539-333f1955ca78"
+			  }
+			}`))
+
+		case "ListAttachedUserPolicies":
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"TotalNum": 2,
+				"List": [
+				  {
+					"PolicyId": 17698703,
+					"PolicyName": "test124-8cc5d5f4-5d36-4a4c-8e4f-3c0c5e2a4b8f",
+					"AddTime": "2020-03-03 18:00:26",
+					"CreateMode": 1,
+					"PolicyType": "User"
+				  },
+				  {
+					"PolicyId": 1,
+					"PolicyName": "AdministratorAccess",
+					"AddTime": "2020-03-03 18:00:26",
+					"CreateMode": 2,
+					"PolicyType": "QCS"
+				  }
+				],
+				"RequestId": "b46d2afe-6893-4529-bc96-2c82d9214957"
+			  }
+			}`))
+
+		case "ListGroupsForUser":
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"TotalNum": 1,
+				"GroupInfo": [
+				  {
+					"GroupId": 2004,
+					"GroupName": "developers",
+					"CreateTime": "2020-03-03 18:00:26",
+					"Remark": ""
+				  }
+				],
+				"RequestId": "b46d2afe-6893-4529-bc96-2c82d9214957"
+			  }
+			}`))
+
+		case "RemoveUserFromGroup":
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"RequestId": "b46d2afe-6893-4529-bc96-2c82d9214957"
+			  }
+			}`))
+
+		case "UpdateAccessKey":
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"RequestId": "99d650e2-10fa-4c8f-819f-874578039641"
+			  }
+			}`))
+
+		case "GetSecurityLastUsed":
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"SecretIdLastUsedRows": [
+				  {
+					"SecretId": "ABBD8GFED7sSr33rSq9KK7h5ISSEoQrFXkmb",
+					"LastUsedDate": "2020-03-04"
+				  }
+				],
+				"RequestId": "99d650e2-10fa-4c8f-819f-874578039641"
+			  }
+			}`))
+
+		case "GetCallerIdentity":
+			authorization := r.Header.Get("Authorization")
+			if strings.Contains(authorization, "Credential=invalid/") ||
+				(strings.Contains(authorization, "Credential=ABBD8GFED7sSr33rSq9KK7h5ISSEoQrFXkmb/") &&
+					atomic.AddInt32(&newKeyUses, 1) == 1) {
+				w.WriteHeader(200)
+				w.Write([]byte(`{
+				  "Response": {
+					"Error": {
+					  "Code": "AuthFailure.SecretIdNotFound",
+					  "Message": "The SecretId is not found, please ensure that your SecretId is correct."
+					},
+					"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+				  }
+				}`))
+				return
+			}
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"Arn": "qcs::cam::uin/100021543443:uin/100021543456",
+				"AccountId": "100021543443",
+				"UserId": "100021543456",
+				"PrincipalId": "100021543456",
+				"Type": "CAMUser",
+				"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+			  }
+			}`))
+
+		case "AssumeRoleWithWebIdentity":
+			if r.Header.Get("Authorization") != "SKIP" {
+				w.WriteHeader(200)
+				w.Write([]byte(`{
+				  "Response": {
+					"Error": {
+					  "Code": "AuthFailure.SignatureFailure",
+					  "Message": "AssumeRoleWithWebIdentity must not be signed"
+					},
+					"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+				  }
+				}`))
+				return
+			}
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+			  "Response": {
+				"Credentials": {
+				  "Token": "da1e9d2ee9dda83506832d5ecb903b790132dfe340001",
+				  "TmpSecretId": "AKID65zyIP0mpXtaI******WIQVMn1umNH58",
+				  "TmpSecretKey": "q95K84wrzuEGoc*******52boxvp71yoh"
+				},
+				"ExpiredTime": 4102444800,
+				"Expiration": "2100-01-01T00:00:00Z",
+				"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+			  }
+			}`))
+
 		case "AssumeRole":
+			// Chained roles only trust the temporary credentials of a hub role.
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "roleName/member") && r.Header.Get("X-TC-Token") == "" {
+				w.WriteHeader(200)
+				w.Write([]byte(`{
+				  "Response": {
+					"Error": {
+					  "Code": "AuthFailure.UnauthorizedOperation",
+					  "Message": "the role may only be assumed by the hub role"
+					},
+					"RequestId": "4daec797-9cd2-4f09-9e7a-7d4c43b2a74c"
+				  }
+				}`))
+				return
+			}
 			w.WriteHeader(200)
 			w.Write([]byte(`    {
 				 "Response": {
@@ -146,8 +480,15 @@ func teardown(ts *httptest.Server) {
 }
 
 func newIntegrationTestEnv(testURL string) (*testEnv, error) {
+	return newIntegrationTestEnvWithSystem(testURL, &logical.StaticSystemView{
+		DefaultLeaseTTLVal: time.Hour,
+		MaxLeaseTTLVal:     time.Hour,
+	})
+}
+
+func newIntegrationTestEnvWithSystem(testURL string, system logical.SystemView) (*testEnv, error) {
 	ctx := context.Background()
-	b, err := proxiedTestBackend(ctx, testURL)
+	b, err := proxiedTestBackend(ctx, testURL, system)
 	if err != nil {
 		return nil, err
 	}
@@ -172,6 +513,8 @@ func TestDynamicPolicyBasedCreds(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	t.Run("add invalid config", integrationTestEnv.AddInvalidConfig)
+	t.Run("add unauthorized config", integrationTestEnv.AddUnauthorizedConfig)
 	t.Run("add config", integrationTestEnv.AddConfig)
 	t.Run("read config", integrationTestEnv.ReadFirstConfig)
 	t.Run("update config", integrationTestEnv.UpdateConfig)
@@ -179,20 +522,259 @@ func TestDynamicPolicyBasedCreds(t *testing.T) {
 	t.Run("delete config", integrationTestEnv.DeleteConfig)
 	t.Run("read config", integrationTestEnv.ReadEmptyConfig)
 	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("check config", integrationTestEnv.CheckConfig)
 
 	t.Run("add policy-based role", integrationTestEnv.AddPolicyBasedRole)
 	t.Run("read policy-based role", integrationTestEnv.ReadPolicyBasedRole)
+	t.Run("preview policy-based role", integrationTestEnv.PreviewPolicyBasedRole)
 	t.Run("add arn-based role", integrationTestEnv.AddARNBasedRole)
+	t.Run("preview arn-based role", integrationTestEnv.PreviewARNBasedRole)
 	t.Run("read arn-based role", integrationTestEnv.ReadARNBasedRole)
+	t.Run("add and read external id role", integrationTestEnv.AddAndReadExternalIdRole)
 	t.Run("update arn-based role", integrationTestEnv.UpdateARNBasedRole)
 	t.Run("read updated role", integrationTestEnv.ReadUpdatedRole)
+	t.Run("list arn-based role versions", integrationTestEnv.ListARNBasedRoleVersions)
+	t.Run("read first arn-based role version", integrationTestEnv.ReadFirstARNBasedRoleVersion)
+	t.Run("export and import roles", integrationTestEnv.ExportAndImportRoles)
+	t.Run("add and read structured policy role", integrationTestEnv.AddAndReadStructuredPolicyRole)
 	t.Run("list two roles", integrationTestEnv.ListTwoRoles)
+	t.Run("list roles detailed", integrationTestEnv.ListRolesDetailed)
 	t.Run("delete arn-based role", integrationTestEnv.DeleteARNBasedRole)
 	t.Run("list one role", integrationTestEnv.ListOneRole)
 
 	t.Run("read policy-based creds", integrationTestEnv.ReadPolicyBasedCreds)
+	t.Run("read issued", integrationTestEnv.ReadIssued)
 	t.Run("renew policy-based creds", integrationTestEnv.RenewPolicyBasedCreds)
 	t.Run("revoke policy-based creds", integrationTestEnv.RevokePolicyBasedCreds)
+	t.Run("read revoked issued", integrationTestEnv.ReadRevokedIssued)
+	t.Run("read policy-based creds with ttl", integrationTestEnv.ReadPolicyBasedCredsWithTTL)
+	t.Run("revoke creds deleted by hand", integrationTestEnv.RevokeDeletedPolicyBasedCreds)
+	t.Run("revoke creds whose access key was deleted by hand", integrationTestEnv.RevokeDeletedAccessKeyPolicyBasedCreds)
+}
+
+// A failed import leaves the roles and their versions as they were.
+func TestFailedImportRollback(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("add policy-based role", integrationTestEnv.AddPolicyBasedRole)
+
+	ctx, s := integrationTestEnv.Context, integrationTestEnv.Storage
+	snapshot := func() map[string][]byte {
+		entries := make(map[string][]byte)
+		for _, prefix := range []string{rolePath, roleVersionPath + "policy-based/", roleVersionPath + "added/"} {
+			keys, err := s.List(ctx, prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range keys {
+				entry, err := s.Get(ctx, prefix+key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				entries[prefix+key] = entry.Value
+			}
+		}
+		return entries
+	}
+	before := snapshot()
+
+	role, err := readRole(ctx, s, "policy-based")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/import",
+		// Writing the second version of either role fails.
+		Storage: &failingStorage{Storage: s, failKey: roleVersionPath + "added/1"},
+		Data: map[string]interface{}{
+			"roles": map[string]interface{}{
+				"policy-based": role,
+				"added":        role,
+			},
+			"conflict_mode": "overwrite",
+		},
+	}
+	if resp, err := integrationTestEnv.Backend.HandleRequest(ctx, req); err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected the import to fail but received %#v", resp)
+	}
+	after := snapshot()
+	if len(after) != len(before) {
+		t.Fatalf("expected entries %v but found %v", keysOf(before), keysOf(after))
+	}
+	for key, value := range before {
+		if !bytes.Equal(after[key], value) {
+			t.Fatalf("expected %s to be restored", key)
+		}
+	}
+}
+
+// failingStorage fails writes to failKey.
+type failingStorage struct {
+	logical.Storage
+	failKey string
+}
+
+func (s *failingStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	if entry.Key == s.failKey {
+		return errors.New("storage unavailable")
+	}
+	return s.Storage.Put(ctx, entry)
+}
+
+func keysOf(entries map[string][]byte) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Roles with a pool hand out pre-created users, and stale members are replaced.
+func TestUserPool(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("add pooled policy-based role", integrationTestEnv.AddPooledPolicyBasedRole)
+	t.Run("preview pooled policy-based role", integrationTestEnv.PreviewPooledPolicyBasedRole)
+	t.Run("fill pool", integrationTestEnv.FillPool)
+	t.Run("read pooled creds", integrationTestEnv.ReadPooledCreds)
+	t.Run("revoke pooled creds", integrationTestEnv.RevokePolicyBasedCreds)
+	t.Run("replace stale pool members", integrationTestEnv.ReplaceStalePoolMembers)
+}
+
+// Nodes that may not write leave the pools to the active node.
+func TestPeriodicFuncOnStandby(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnvWithSystem(ts.URL, &logical.StaticSystemView{
+		DefaultLeaseTTLVal:  time.Hour,
+		MaxLeaseTTLVal:      time.Hour,
+		ReplicationStateVal: consts.ReplicationPerformanceStandby,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("add pooled policy-based role", integrationTestEnv.AddPooledPolicyBasedRole)
+	t.Run("skip periodic func", integrationTestEnv.SkipPeriodicFunc)
+}
+
+// Reading creds fails fast once a role or the mount reaches its limits.
+func TestLeaseLimits(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("enforce lease limits", integrationTestEnv.EnforceLeaseLimits)
+}
+
+// Issued credentials can be found and revoked by access key, username or UIN.
+func TestLookupIssued(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("add policy-based role", integrationTestEnv.AddPolicyBasedRole)
+	t.Run("read policy-based creds", integrationTestEnv.ReadPolicyBasedCreds)
+	t.Run("lookup and revoke issued", integrationTestEnv.LookupAndRevokeIssued)
+	t.Run("revoke policy-based creds", integrationTestEnv.RevokePolicyBasedCreds)
+	t.Run("read revoked issued", integrationTestEnv.ReadRevokedIssued)
+}
+
+// STS credentials can only be revoked through lookup when their role denies
+// revoked sessions with the configured credentials.
+func TestLookupIssuedSTS(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrationTestEnv.RoleARN = "qcs::cam::uin/100021543443:roleName/firingrole001"
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("add arn-based role", integrationTestEnv.AddARNBasedRole)
+	t.Run("read arn-based creds", integrationTestEnv.ReadARNBasedCreds)
+	t.Run("lookup and revoke unrevokable issued", integrationTestEnv.LookupAndRevokeUnrevokableIssuedSTS)
+	t.Run("add chained arn-based role", integrationTestEnv.AddChainedARNBasedRole)
+	t.Run("read arn-based creds", integrationTestEnv.ReadARNBasedCreds)
+	t.Run("lookup and revoke unrevokable issued", integrationTestEnv.LookupAndRevokeUnrevokableIssuedSTS)
+	t.Run("delete arn-based role", integrationTestEnv.DeleteARNBasedRole)
+	t.Run("add revoking arn-based role", integrationTestEnv.AddRevokingARNBasedRole)
+	t.Run("read arn-based creds", integrationTestEnv.ReadARNBasedCreds)
+	t.Run("lookup and revoke issued", integrationTestEnv.LookupAndRevokeIssuedSTS)
+}
+
+// Roles written and imported at the same time each get a version of their own.
+func TestConcurrentRoleWrites(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("write role concurrently", integrationTestEnv.WriteRoleConcurrently)
+}
+
+// A CAM user can be deleted with everything CAM lists for it, lease or not.
+func TestCleanupUser(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("add policy-based role", integrationTestEnv.AddPolicyBasedRole)
+	t.Run("read policy-based creds", integrationTestEnv.ReadPolicyBasedCreds)
+	t.Run("cleanup user", integrationTestEnv.CleanupUser)
+	t.Run("revoke policy-based creds", integrationTestEnv.RevokePolicyBasedCreds)
+	t.Run("read revoked issued", integrationTestEnv.ReadRevokedIssued)
+}
+
+// Reading creds waits for the new access key to be accepted when the role asks to.
+func TestWaitForPropagation(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("add propagating policy-based role", integrationTestEnv.AddPropagatingPolicyBasedRole)
+	t.Run("read propagated creds", integrationTestEnv.ReadPropagatedCreds)
 }
 
 // Since all endpoints were exercised in the previous test, we just need one that
@@ -213,7 +795,280 @@ func TestDynamicSTSCreds(t *testing.T) {
 	t.Run("revoke arn-based creds", integrationTestEnv.RevokeARNBasedCreds)
 }
 
-func proxiedTestBackend(context context.Context, testURL string) (logical.Backend, error) {
+func TestRenewableSTSCreds(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("add renewable arn-based role", integrationTestEnv.AddRenewableARNBasedRole)
+	t.Run("read arn-based creds", integrationTestEnv.ReadARNBasedCreds)
+	t.Run("renew renewable arn-based creds", integrationTestEnv.RenewRenewableARNBasedCreds)
+	t.Run("revoke arn-based creds", integrationTestEnv.RevokeARNBasedCreds)
+}
+
+func TestRevokeSTSSession(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrationTestEnv.RoleARN = "qcs::cam::uin/100021543443:roleName/firingrole001"
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("add revoking arn-based role", integrationTestEnv.AddRevokingARNBasedRole)
+	t.Run("add cross-account revoking arn-based role", integrationTestEnv.AddCrossAccountRevokingARNBasedRole)
+	t.Run("read arn-based creds", integrationTestEnv.ReadARNBasedCreds)
+	t.Run("revoke arn-based creds", integrationTestEnv.RevokeARNBasedCreds)
+	t.Run("recreate revoked sessions policy", integrationTestEnv.RecreateRevokedSessionsPolicy)
+	t.Run("tidy revoked sessions", integrationTestEnv.TidyRevokedSessions)
+}
+
+func TestChainedSTSCreds(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrationTestEnv.RoleARN = "qcs::cam::uin/100021543443:roleName/member"
+
+	t.Run("add config", integrationTestEnv.AddConfig)
+	t.Run("add chained arn-based role", integrationTestEnv.AddChainedARNBasedRole)
+	t.Run("read arn-based creds", integrationTestEnv.ReadARNBasedCreds)
+	t.Run("revoke arn-based creds", integrationTestEnv.RevokeARNBasedCreds)
+}
+
+func TestWebIdentityConfig(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	unsupportedTestEnv, err := newIntegrationTestEnvWithSystem(ts.URL, &identityTokenSystemView{
+		err: status.Error(codes.Internal, pluginidentityutil.ErrPluginWorkloadIdentityUnsupported.Error()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("add unsupported web identity config", unsupportedTestEnv.AddUnsupportedWebIdentityConfig)
+
+	// Vault servers older than 1.16 don't implement GenerateIdentityToken.
+	unimplementedTestEnv, err := newIntegrationTestEnvWithSystem(ts.URL, &identityTokenSystemView{
+		err: status.Error(codes.Unimplemented, "unknown method GenerateIdentityToken for service pb.SystemView"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("add unimplemented web identity config", unimplementedTestEnv.AddUnsupportedWebIdentityConfig)
+
+	integrationTestEnv, err := newIntegrationTestEnvWithSystem(ts.URL, &identityTokenSystemView{
+		StaticSystemView: logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour,
+			MaxLeaseTTLVal:     time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add web identity config", integrationTestEnv.AddWebIdentityConfig)
+	t.Run("add policy-based role", integrationTestEnv.AddPolicyBasedRole)
+	t.Run("read policy-based creds", integrationTestEnv.ReadPolicyBasedCreds)
+	t.Run("revoke policy-based creds", integrationTestEnv.RevokePolicyBasedCreds)
+}
+
+// Temporary credentials are only renewed by GetCredential, and every credential
+// it returned keeps its values.
+func TestCachedProviderSnapshots(t *testing.T) {
+	var fetches int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&fetches, 1)
+		if n > 2 {
+			w.WriteHeader(500)
+			return
+		}
+		// The credentials expire within the refresh window, so every
+		// GetCredential renews them.
+		w.WriteHeader(200)
+		fmt.Fprintf(w, `{
+		  "TmpSecretId": "AKIDcvmrole%d",
+		  "TmpSecretKey": "cvmrolekey%d",
+		  "ExpiredTime": %d,
+		  "Token": "cvmroletoken%d",
+		  "Code": "Success"
+		}`, n, n, time.Now().Add(time.Minute).Unix(), n)
+	}))
+	defer ts.Close()
+
+	profile := clients.NewClientProfile()
+	transport := &http.Transport{}
+	capturer, _ := newURLUpdater(ts.URL)
+	transport.Proxy = capturer.Proxy
+	profile.HttpTransport = transport
+	provider := clients.NewCVMRoleProvider(profile, "vault-cvm")
+
+	first, err := provider.GetCredential()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := provider.GetCredential()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.GetSecretId() != "AKIDcvmrole1" || first.GetSecretKey() != "cvmrolekey1" || first.GetToken() != "cvmroletoken1" {
+		t.Fatalf("expected the first credential to keep its values but received %s", first.GetSecretId())
+	}
+	if second.GetSecretId() != "AKIDcvmrole2" || second.GetToken() != "cvmroletoken2" {
+		t.Fatalf("expected a renewed credential but received %s", second.GetSecretId())
+	}
+
+	// A failed renewal keeps credentials that haven't expired yet.
+	third, err := provider.GetCredential()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.GetSecretId() != "AKIDcvmrole2" || third.GetSecretKey() != "cvmrolekey2" {
+		t.Fatalf("expected the previous credential but received %s", third.GetSecretId())
+	}
+	if atomic.LoadInt32(&fetches) != 3 {
+		t.Fatalf("expected 3 fetches but received %d", fetches)
+	}
+}
+
+func TestCVMRoleConfig(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add cvm role config", integrationTestEnv.AddCVMRoleConfig)
+	t.Run("read cvm role config", integrationTestEnv.ReadCVMRoleConfig)
+	t.Run("add policy-based role", integrationTestEnv.AddPolicyBasedRole)
+	t.Run("read policy-based creds", integrationTestEnv.ReadPolicyBasedCreds)
+	t.Run("revoke policy-based creds", integrationTestEnv.RevokePolicyBasedCreds)
+	t.Run("delete config", integrationTestEnv.DeleteConfig)
+	t.Run("forget credential providers", func(t *testing.T) {
+		b := integrationTestEnv.Backend.(*backend)
+		b.credentialProvidersLock.Lock()
+		defer b.credentialProvidersLock.Unlock()
+		if len(b.credentialProviders) != 0 {
+			t.Fatalf("expected no cached credential providers but found %d", len(b.credentialProviders))
+		}
+	})
+}
+
+// Access keys can be listed, deactivated and checked for use through the local SDK.
+func TestAccessKeys(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("add config", integrationTestEnv.AddConfig)
+
+	b := integrationTestEnv.Backend.(*backend)
+	client, err := b.accountCAMClient(integrationTestEnv.Context, integrationTestEnv.Storage, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uin := uint64(100000546533)
+	keys, err := client.ListAccessKeys(&uin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.Response.AccessKeys) != 1 || *keys.Response.AccessKeys[0].Status != clients.AccessKeyActive {
+		t.Fatalf("unexpected access keys %s", keys.ToJsonString())
+	}
+	accessKeyId := keys.Response.AccessKeys[0].AccessKeyId
+	if err := client.UpdateAccessKey(accessKeyId, clients.AccessKeyInactive, &uin); err != nil {
+		t.Fatal(err)
+	}
+	lastUsed, err := client.GetSecurityLastUsed([]*string{accessKeyId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := lastUsed.Response.SecretIdLastUsedRows
+	if len(rows) != 1 || *rows[0].SecretId != *accessKeyId || *rows[0].LastUsedDate != "2020-03-04" {
+		t.Fatalf("unexpected last used %s", lastUsed.ToJsonString())
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("add config", integrationTestEnv.AddConfig)
+
+	b := integrationTestEnv.Backend.(*backend)
+	ctx, s := integrationTestEnv.Context, integrationTestEnv.Storage
+	first, err := b.clientsFor(ctx, s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := b.clientsFor(ctx, s, ""); cached != first {
+		t.Fatal("expected the clients to be cached")
+	}
+
+	// A standby learns of config writes through Invalidate.
+	b.invalidate(ctx, "config")
+	rebuilt, err := b.clientsFor(ctx, s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt == first {
+		t.Fatal("expected the clients to be rebuilt after the config was invalidated")
+	}
+
+	t.Run("update config", integrationTestEnv.UpdateConfig)
+	updated, err := b.clientsFor(ctx, s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated == rebuilt || updated.creds.SecretId != "foo" {
+		t.Fatal("expected the clients to be rebuilt from the updated config")
+	}
+
+	t.Run("delete config", integrationTestEnv.DeleteConfig)
+	if deleted, _ := b.clientsFor(ctx, s, ""); deleted != nil {
+		t.Fatal("expected no clients once the config is deleted")
+	}
+}
+
+// Roles using a named account work without any default config.
+func TestNamedAccounts(t *testing.T) {
+	ts := setup()
+	defer teardown(ts)
+
+	integrationTestEnv, err := newIntegrationTestEnv(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("add account", integrationTestEnv.AddAccount)
+	t.Run("read account", integrationTestEnv.ReadAccount)
+	t.Run("add account-based role", integrationTestEnv.AddAccountBasedRole)
+	t.Run("read account-based creds", integrationTestEnv.ReadAccountBasedCreds)
+	t.Run("renew account-based creds", integrationTestEnv.RenewPolicyBasedCreds)
+	t.Run("revoke account-based creds", integrationTestEnv.RevokePolicyBasedCreds)
+	t.Run("delete account in use", integrationTestEnv.DeleteAccountInUse)
+}
+
+func proxiedTestBackend(context context.Context, testURL string, system logical.SystemView) (logical.Backend, error) {
 
 	profile := clients.NewClientProfile()
 	transport := &http.Transport{}
@@ -222,10 +1077,7 @@ func proxiedTestBackend(context context.Context, testURL string) (logical.Backen
 	profile.HttpTransport = transport
 	profile.HttpProfile.Scheme = "HTTP"
 	conf := &logical.BackendConfig{
-		System: &logical.StaticSystemView{
-			DefaultLeaseTTLVal: time.Hour,
-			MaxLeaseTTLVal:     time.Hour,
-		},
+		System: system,
 	}
 	b := newBackend(profile)
 	if err := b.Setup(context, conf); err != nil {
@@ -234,6 +1086,24 @@ func proxiedTestBackend(context context.Context, testURL string) (logical.Backen
 	return b, nil
 }
 
+// identityTokenSystemView issues plugin identity tokens like Vault Enterprise,
+// or fails with err like Vault community edition.
+type identityTokenSystemView struct {
+	logical.StaticSystemView
+	err error
+}
+
+func (v *identityTokenSystemView) GenerateIdentityToken(_ context.Context,
+	req *pluginutil.IdentityTokenRequest) (*pluginutil.IdentityTokenResponse, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	return &pluginutil.IdentityTokenResponse{
+		Token: pluginutil.IdentityToken("eyJhbGciOiJSUzI1NiJ9." + req.Audience),
+		TTL:   req.TTL,
+	}, nil
+}
+
 /*
 	The URL updater uses the Proxy on outbound requests to swap
 	a real URL with one generated by httptest. This points requests
@@ -0,0 +1,86 @@
+package tencentcloud
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// camReqTimeout is the request timeout, in seconds, of CAM clients. Issuing
+// CAM credentials makes many calls that can be slow to return.
+const camReqTimeout = 600
+
+// accountClients are the clients built from the config of one account.
+type accountClients struct {
+	creds *credConfig
+	cam   *clients.CAMClient
+	sts   *clients.STSClient
+}
+
+// clientsFor returns the cached clients of an account, or of "config" when
+// account is empty, building them on first use. It returns nil if the account
+// is not configured. Clients using temporary credentials are not cached.
+func (b *backend) clientsFor(ctx context.Context, s logical.Storage, account string) (*accountClients, error) {
+	b.clientsLock.RLock()
+	cached, ok := b.clients[account]
+	generation := b.clientsGeneration
+	b.clientsLock.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	creds, err := readAccountConfig(ctx, s, account)
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		return nil, nil
+	}
+	camClient, err := clients.NewCAMClient(b.profile.WithReqTimeout(camReqTimeout), b.camConfig(creds))
+	if err != nil {
+		return nil, err
+	}
+	stsClient, err := clients.NewSTSClient(b.profile, b.stsConfig(creds))
+	if err != nil {
+		return nil, err
+	}
+	built := &accountClients{creds: creds, cam: camClient, sts: stsClient}
+
+	// Clients built from temporary credentials hold a snapshot of them, so
+	// they are built again for every request instead of outliving it.
+	if b.credentialProvider(creds) != nil {
+		return built, nil
+	}
+
+	b.clientsLock.Lock()
+	defer b.clientsLock.Unlock()
+	// A config written while these clients were built may not be reflected in
+	// them, so they are used for this request but not cached.
+	if generation == b.clientsGeneration {
+		b.clients[account] = built
+	}
+	return built, nil
+}
+
+// resetClients drops the cached clients of an account, and the cached
+// credential providers it may have used.
+func (b *backend) resetClients(account string) {
+	b.resetCredentialProviders()
+	b.clientsLock.Lock()
+	defer b.clientsLock.Unlock()
+	delete(b.clients, account)
+	b.clientsGeneration++
+}
+
+// invalidate drops cached clients when their config changes, including on
+// performance standbys where the write happened on another node.
+func (b *backend) invalidate(ctx context.Context, key string) {
+	switch {
+	case key == configStoragePath:
+		b.resetClients("")
+	case strings.HasPrefix(key, accountStoragePath):
+		b.resetClients(strings.TrimPrefix(key, accountStoragePath))
+	}
+}
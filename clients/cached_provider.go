@@ -0,0 +1,53 @@
+package clients
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+)
+
+// refreshWindow is how long before expiry cached temporary credentials are
+// replaced.
+const refreshWindow = 5 * time.Minute
+
+// fetchFunc obtains new temporary credentials and when they expire.
+type fetchFunc func() (*common.Credential, time.Time, error)
+
+// CachedProvider caches temporary credentials and fetches new ones shortly
+// before they expire. Every GetCredential returns a copy that never changes,
+// so a client built from it signs each request with one set of credentials.
+type CachedProvider struct {
+	fetch fetchFunc
+
+	lock       sync.Mutex
+	credential *common.Credential
+	expiration time.Time
+}
+
+// GetCredential returns a snapshot of the cached credentials, renewing them
+// first when they are about to expire. A failed renewal keeps the previous
+// credentials until they have actually expired.
+func (p *CachedProvider) GetCredential() (common.CredentialIface, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.credential == nil || time.Until(p.expiration) < refreshWindow {
+		credential, expiration, err := p.fetch()
+		if err != nil && (p.credential == nil || !time.Now().Before(p.expiration)) {
+			return nil, err
+		}
+		if err == nil {
+			p.credential = credential
+			p.expiration = expiration
+		}
+	}
+	return common.NewTokenCredential(p.credential.SecretId, p.credential.SecretKey, p.credential.Token), nil
+}
+
+// Expiration returns when the current temporary credentials expire, or the
+// zero time if none have been obtained yet.
+func (p *CachedProvider) Expiration() time.Time {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.expiration
+}
@@ -3,20 +3,26 @@ package clients
 import (
 	camLocal "github.com/hashicorp/vault-plugin-secrets-tencentcloud/sdk/tencentcloud/cam/v20190116"
 	cam "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
-	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/regions"
+)
+
+// The statuses of an access key.
+const (
+	AccessKeyActive   = "Active"
+	AccessKeyInactive = "Inactive"
 )
 
 // NewCAMClient
-func NewCAMClient(clientProfile *ClientProfile, secretId, secretKey string) (*CAMClient, error) {
-	creds, err := chainedCreds(secretId, secretKey)
+func NewCAMClient(clientProfile *ClientProfile, config *Configuration) (*CAMClient, error) {
+	creds, err := chainedCreds(config)
 	if err != nil {
 		return nil, err
 	}
-	client, err := cam.NewClient(creds, regions.Ashburn, clientProfile.ClientProfile)
+	cpf := clientProfile.forEndpoint(config.Endpoint)
+	client, err := cam.NewClient(creds, config.region(), cpf)
 	if err != nil {
 		return nil, err
 	}
-	clientLocal, err := camLocal.NewClient(creds, regions.Ashburn, clientProfile.ClientProfile)
+	clientLocal, err := camLocal.NewClient(creds, config.region(), cpf)
 	if err != nil {
 		return nil, err
 	}
@@ -50,6 +56,32 @@ func (c *CAMClient) DeleteAccessKey(accessKeyId *string, targetUin *uint64) erro
 	return err
 }
 
+// ListAccessKeys
+func (c *CAMClient) ListAccessKeys(targetUin *uint64) (*camLocal.ListAccessKeysResponse, error) {
+	req := camLocal.NewListAccessKeysRequest()
+	req.TargetUin = targetUin
+	return c.clientLocal.ListAccessKeys(req)
+}
+
+// UpdateAccessKey sets the status of an access key to AccessKeyActive or
+// AccessKeyInactive. Inactive keys are rejected but can be activated again.
+func (c *CAMClient) UpdateAccessKey(accessKeyId *string, status string, targetUin *uint64) error {
+	req := camLocal.NewUpdateAccessKeyRequest()
+	req.AccessKeyId = accessKeyId
+	req.Status = &status
+	req.TargetUin = targetUin
+	_, err := c.clientLocal.UpdateAccessKey(req)
+	return err
+}
+
+// GetSecurityLastUsed returns when each access key was last used. Usage is
+// reported with a delay of about a day.
+func (c *CAMClient) GetSecurityLastUsed(accessKeyIds []*string) (*camLocal.GetSecurityLastUsedResponse, error) {
+	req := camLocal.NewGetSecurityLastUsedRequest()
+	req.SecretIdList = accessKeyIds
+	return c.clientLocal.GetSecurityLastUsed(req)
+}
+
 // CreatePolicy
 func (c *CAMClient) CreatePolicy(policyName string, policyDocument string) (*cam.CreatePolicyResponse, error) {
 	description := "Created by Vault."
@@ -108,3 +140,69 @@ func (c *CAMClient) ListPolicies(keyWord string, scope string) (*cam.ListPolicie
 	req.Keyword = &keyWord
 	return c.client.ListPolicies(req)
 }
+
+// UpdatePolicy
+func (c *CAMClient) UpdatePolicy(policyId *uint64, policyDocument string) error {
+	req := cam.NewUpdatePolicyRequest()
+	req.PolicyId = policyId
+	req.PolicyDocument = &policyDocument
+	_, err := c.client.UpdatePolicy(req)
+	return err
+}
+
+// AttachRolePolicy
+func (c *CAMClient) AttachRolePolicy(policyId *uint64, roleName string) error {
+	req := cam.NewAttachRolePolicyRequest()
+	req.PolicyId = policyId
+	req.AttachRoleName = &roleName
+	_, err := c.client.AttachRolePolicy(req)
+	return err
+}
+
+// DetachRolePolicy
+func (c *CAMClient) DetachRolePolicy(policyId *uint64, roleName string) error {
+	req := cam.NewDetachRolePolicyRequest()
+	req.PolicyId = policyId
+	req.DetachRoleName = &roleName
+	_, err := c.client.DetachRolePolicy(req)
+	return err
+}
+
+// GetUser
+func (c *CAMClient) GetUser(userName string) (*cam.GetUserResponse, error) {
+	req := cam.NewGetUserRequest()
+	req.Name = &userName
+	return c.client.GetUser(req)
+}
+
+// ListUsers
+func (c *CAMClient) ListUsers() (*cam.ListUsersResponse, error) {
+	req := cam.NewListUsersRequest()
+	return c.client.ListUsers(req)
+}
+
+// ListAttachedUserPolicies
+func (c *CAMClient) ListAttachedUserPolicies(targetUin *uint64, page, rp uint64) (*cam.ListAttachedUserPoliciesResponse, error) {
+	req := cam.NewListAttachedUserPoliciesRequest()
+	req.TargetUin = targetUin
+	req.Page = &page
+	req.Rp = &rp
+	return c.client.ListAttachedUserPolicies(req)
+}
+
+// ListGroupsForUser
+func (c *CAMClient) ListGroupsForUser(subUin *uint64, page, rp uint64) (*cam.ListGroupsForUserResponse, error) {
+	req := cam.NewListGroupsForUserRequest()
+	req.SubUin = subUin
+	req.Page = &page
+	req.Rp = &rp
+	return c.client.ListGroupsForUser(req)
+}
+
+// RemoveUserFromGroup
+func (c *CAMClient) RemoveUserFromGroup(uid *uint64, groupId *uint64) error {
+	req := cam.NewRemoveUserFromGroupRequest()
+	req.Info = []*cam.GroupIdOfUidInfo{{Uid: uid, GroupId: groupId}}
+	_, err := c.client.RemoveUserFromGroup(req)
+	return err
+}
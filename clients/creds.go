@@ -4,13 +4,19 @@ import (
 	"fmt"
 
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/regions"
 )
 
-func chainedCreds(secretId, secretKey string) (common.CredentialIface, error) {
+// chainedCreds prefers the configured secret id and key, so that every named
+// account uses its own credentials even when the environment holds others.
+func chainedCreds(config *Configuration) (common.CredentialIface, error) {
+	if config.Provider != nil {
+		return config.Provider.GetCredential()
+	}
 	providerChain := []common.Provider{
+		NewConfigurationCredentialProvider(config),
 		common.DefaultEnvProvider(),
 		common.DefaultProfileProvider(),
-		NewConfigurationCredentialProvider(&Configuration{secretId, secretKey}),
 		common.DefaultCvmRoleProvider(),
 	}
 	return common.NewProviderChain(providerChain).GetCredential()
@@ -20,6 +26,22 @@ func chainedCreds(secretId, secretKey string) (common.CredentialIface, error) {
 type Configuration struct {
 	SecretId  string
 	SecretKey string
+	// Token is set for temporary credentials returned by STS.
+	Token string
+	// Region defaults to ap-ashburn when empty.
+	Region string
+	// Endpoint overrides the default endpoint of the service when set.
+	Endpoint string
+	// Provider, when set, is used instead of the secret id and key and the
+	// default providers.
+	Provider common.Provider
+}
+
+func (c *Configuration) region() string {
+	if c.Region == "" {
+		return regions.Ashburn
+	}
+	return c.Region
 }
 
 // NewConfigurationCredentialProvider
@@ -37,6 +59,10 @@ type ConfigurationProvider struct {
 // GetCredential
 func (p *ConfigurationProvider) GetCredential() (common.CredentialIface, error) {
 	if p.Configuration.SecretId != "" && p.Configuration.SecretKey != "" {
+		if p.Configuration.Token != "" {
+			return common.NewTokenCredential(p.Configuration.SecretId, p.Configuration.SecretKey,
+				p.Configuration.Token), nil
+		}
 		return common.NewCredential(p.Configuration.SecretId, p.Configuration.SecretKey), nil
 	} else {
 		return nil, ErrNoValidCredentialsFound
@@ -0,0 +1,60 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+)
+
+// cvmRoleCredentialsURL serves the temporary credentials of the role bound to
+// the CVM instance, see https://cloud.tencent.com/document/product/213/4934
+const cvmRoleCredentialsURL = "http://metadata.tencentyun.com/latest/meta-data/cam/security-credentials/"
+
+type cvmRoleCredentials struct {
+	TmpSecretId  string `json:"TmpSecretId"`
+	TmpSecretKey string `json:"TmpSecretKey"`
+	ExpiredTime  int64  `json:"ExpiredTime"`
+	Token        string `json:"Token"`
+	Code         string `json:"Code"`
+}
+
+// NewCVMRoleProvider returns a provider for the temporary credentials of the
+// named role bound to the CVM instance Vault runs on.
+func NewCVMRoleProvider(clientProfile *ClientProfile, roleName string) *CachedProvider {
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	// proxy server
+	if clientProfile.HttpTransport != nil {
+		httpClient.Transport = clientProfile.HttpTransport
+	}
+	return &CachedProvider{
+		fetch: func() (*common.Credential, time.Time, error) {
+			resp, err := httpClient.Get(cvmRoleCredentialsURL + roleName)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, time.Time{}, fmt.Errorf("role %s is not bound to this CVM instance", roleName)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			credentials := &cvmRoleCredentials{}
+			if err := json.Unmarshal(body, credentials); err != nil {
+				return nil, time.Time{}, err
+			}
+			if credentials.Code != "Success" {
+				return nil, time.Time{}, fmt.Errorf("unable to get credentials of role %s: %s", roleName, credentials.Code)
+			}
+			return common.NewTokenCredential(credentials.TmpSecretId, credentials.TmpSecretKey, credentials.Token),
+				time.Unix(credentials.ExpiredTime, 0), nil
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"errors"
+	"strings"
+
+	camLocal "github.com/hashicorp/vault-plugin-secrets-tencentcloud/sdk/tencentcloud/cam/v20190116"
+	cam "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
+	tcerr "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+)
+
+// notFoundCodes are the codes, besides those of the ResourceNotFound class,
+// that CAM returns when the user, group, policy, role or access key of a call
+// doesn't exist. CAM has no more specific code for a missing access key than
+// FailedOperation.Accesskey.
+var notFoundCodes = map[string]bool{
+	camLocal.INVALIDPARAMETER_USERNOTEXIST: true,
+	camLocal.FAILEDOPERATION_ACCESSKEY:     true,
+	cam.INVALIDPARAMETER_GROUPNOTEXIST:     true,
+	cam.INVALIDPARAMETER_POLICYIDNOTEXIST:  true,
+	cam.INVALIDPARAMETER_ROLENOTEXIST:      true,
+}
+
+// IsNotFound reports whether err is a Tencent Cloud error saying the resource
+// it was about no longer exists.
+func IsNotFound(err error) bool {
+	var sdkErr *tcerr.TencentCloudSDKError
+	if !errors.As(err, &sdkErr) {
+		return false
+	}
+	code := sdkErr.GetCode()
+	return strings.HasPrefix(code, "ResourceNotFound.") || notFoundCodes[code]
+}
+
+// IgnoreNotFound returns nil for errors IsNotFound reports, and err otherwise.
+func IgnoreNotFound(err error) error {
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// IsUnauthorized reports whether err is a Tencent Cloud error saying the
+// credentials are invalid or not allowed to make the call.
+func IsUnauthorized(err error) bool {
+	var sdkErr *tcerr.TencentCloudSDKError
+	if !errors.As(err, &sdkErr) {
+		return false
+	}
+	code := sdkErr.GetCode()
+	return strings.HasPrefix(code, "AuthFailure") || strings.HasPrefix(code, "UnauthorizedOperation")
+}
@@ -22,3 +22,29 @@ func NewClientProfile() *ClientProfile {
 	return clientProFile
 
 }
+
+// forEndpoint returns a copy of the profile that sends requests to endpoint,
+// or the profile itself when endpoint is empty.
+func (p *ClientProfile) forEndpoint(endpoint string) *profile.ClientProfile {
+	if endpoint == "" {
+		return p.ClientProfile
+	}
+	clientProfile := *p.ClientProfile
+	httpProfile := *clientProfile.HttpProfile
+	httpProfile.Endpoint = endpoint
+	clientProfile.HttpProfile = &httpProfile
+	return &clientProfile
+}
+
+// WithReqTimeout returns a copy of the profile whose requests time out after
+// the given number of seconds.
+func (p *ClientProfile) WithReqTimeout(seconds int) *ClientProfile {
+	clientProfile := *p.ClientProfile
+	httpProfile := *clientProfile.HttpProfile
+	httpProfile.ReqTimeout = seconds
+	clientProfile.HttpProfile = &httpProfile
+	return &ClientProfile{
+		ClientProfile: &clientProfile,
+		HttpTransport: p.HttpTransport,
+	}
+}
@@ -1,37 +1,54 @@
 package clients
 
 import (
-	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/regions"
+	stsLocal "github.com/hashicorp/vault-plugin-secrets-tencentcloud/sdk/tencentcloud/sts/v20180813"
 	sts "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sts/v20180813"
 )
 
 // NewSTSClient
-func NewSTSClient(clientProfile *ClientProfile, secretId, secretKey string) (*STSClient, error) {
-	creds, err := chainedCreds(secretId, secretKey)
+func NewSTSClient(clientProfile *ClientProfile, config *Configuration) (*STSClient, error) {
+	creds, err := chainedCreds(config)
 	if err != nil {
 		return nil, err
 	}
-	client, err := sts.NewClient(creds, regions.Ashburn, clientProfile.ClientProfile)
-	// proxy serve
-	if clientProfile.HttpTransport != nil {
-		client.WithHttpTransport(clientProfile.HttpTransport)
+	cpf := clientProfile.forEndpoint(config.Endpoint)
+	client, err := sts.NewClient(creds, config.region(), cpf)
+	if err != nil {
+		return nil, err
 	}
+	clientLocal, err := stsLocal.NewClient(creds, config.region(), cpf)
 	if err != nil {
 		return nil, err
 	}
-	return &STSClient{client: client}, nil
+	// proxy serve
+	if clientProfile.HttpTransport != nil {
+		client.WithHttpTransport(clientProfile.HttpTransport)
+		clientLocal.WithHttpTransport(clientProfile.HttpTransport)
+	}
+	return &STSClient{client: client, clientLocal: clientLocal}, nil
 }
 
 // STSClient
 type STSClient struct {
-	client *sts.Client
+	client      *sts.Client
+	clientLocal *stsLocal.Client
 }
 
-// AssumeRole
-func (c *STSClient) AssumeRole(roleSessionName, roleARN, externalId string) (*sts.AssumeRoleResponse, error) {
+// GetCallerIdentity returns who the client's credentials belong to.
+func (c *STSClient) GetCallerIdentity() (*stsLocal.GetCallerIdentityResponse, error) {
+	return c.clientLocal.GetCallerIdentity(stsLocal.NewGetCallerIdentityRequest())
+}
+
+// AssumeRole requests credentials valid for durationSeconds, or for the STS
+// default when durationSeconds is 0.
+func (c *STSClient) AssumeRole(roleSessionName, roleARN, externalId string,
+	durationSeconds uint64) (*sts.AssumeRoleResponse, error) {
 	assumeRoleReq := sts.NewAssumeRoleRequest()
 	assumeRoleReq.RoleSessionName = &roleSessionName
 	assumeRoleReq.RoleArn = &roleARN
 	assumeRoleReq.ExternalId = &externalId
+	if durationSeconds > 0 {
+		assumeRoleReq.DurationSeconds = &durationSeconds
+	}
 	return c.client.AssumeRole(assumeRoleReq)
 }
@@ -0,0 +1,46 @@
+package clients
+
+import (
+	"time"
+
+	stsLocal "github.com/hashicorp/vault-plugin-secrets-tencentcloud/sdk/tencentcloud/sts/v20180813"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+)
+
+// IdentityTokenFunc returns a fresh identity token to exchange for credentials.
+type IdentityTokenFunc func() (string, error)
+
+// NewWebIdentityProvider returns a provider that exchanges identity tokens for
+// temporary credentials of a role with STS AssumeRoleWithWebIdentity.
+func NewWebIdentityProvider(clientProfile *ClientProfile, config *Configuration,
+	providerId, roleARN, roleSessionName string, identityToken IdentityTokenFunc) *CachedProvider {
+	return &CachedProvider{
+		fetch: func() (*common.Credential, time.Time, error) {
+			token, err := identityToken()
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			client, err := stsLocal.NewClient(common.NewCredential("", ""), config.region(),
+				clientProfile.forEndpoint(config.Endpoint))
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			// proxy server
+			if clientProfile.HttpTransport != nil {
+				client.WithHttpTransport(clientProfile.HttpTransport)
+			}
+			req := stsLocal.NewAssumeRoleWithWebIdentityRequest()
+			req.ProviderId = &providerId
+			req.WebIdentityToken = &token
+			req.RoleArn = &roleARN
+			req.RoleSessionName = &roleSessionName
+			resp, err := client.AssumeRoleWithWebIdentity(req)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			credentials := resp.Response.Credentials
+			return common.NewTokenCredential(*credentials.TmpSecretId, *credentials.TmpSecretKey, *credentials.Token),
+				time.Unix(*resp.Response.ExpiredTime, 0), nil
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
+	"github.com/hashicorp/vault/sdk/helper/pluginutil"
+)
+
+const (
+	// credentialSourceCVMRole uses only the role bound to the CVM instance.
+	credentialSourceCVMRole = "cvm_role"
+
+	defaultIdentityTokenTTL = time.Hour
+
+	webIdentitySessionName = "vault-secrets-tencentcloud"
+)
+
+// credentialProvider returns the provider of temporary credentials for creds,
+// or nil when creds hold a secret key. Providers are shared by every config
+// with the same settings, so their credentials are reused until they expire.
+func (b *backend) credentialProvider(creds *credConfig) *clients.CachedProvider {
+	switch {
+	case creds.CredentialSource == credentialSourceCVMRole:
+		return b.cachedProvider("cvm_role|"+creds.CVMRoleName, func() *clients.CachedProvider {
+			return clients.NewCVMRoleProvider(b.profile, creds.CVMRoleName)
+		})
+	case creds.RoleARN != "":
+		return b.webIdentityProvider(creds)
+	default:
+		return nil
+	}
+}
+
+func (b *backend) webIdentityProvider(creds *credConfig) *clients.CachedProvider {
+	ttl := creds.IdentityTokenTTL
+	if ttl == 0 {
+		ttl = defaultIdentityTokenTTL
+	}
+	key := fmt.Sprintf("web_identity|%s|%s|%s|%d|%s|%s", creds.RoleARN, creds.IdentityProviderId,
+		creds.IdentityTokenAudience, ttl, creds.Region, creds.STSEndpoint)
+	return b.cachedProvider(key, func() *clients.CachedProvider {
+		audience := creds.IdentityTokenAudience
+		identityToken := func() (string, error) {
+			resp, err := b.System().GenerateIdentityToken(context.Background(), &pluginutil.IdentityTokenRequest{
+				Audience: audience,
+				TTL:      ttl,
+			})
+			if err != nil {
+				return "", fmt.Errorf("unable to generate a plugin identity token: %w", err)
+			}
+			return resp.Token.Token(), nil
+		}
+		return clients.NewWebIdentityProvider(b.profile, &clients.Configuration{
+			Region:   creds.Region,
+			Endpoint: creds.STSEndpoint,
+		}, creds.IdentityProviderId, creds.RoleARN, webIdentitySessionName, identityToken)
+	})
+}
+
+func (b *backend) cachedProvider(key string, build func() *clients.CachedProvider) *clients.CachedProvider {
+	b.credentialProvidersLock.Lock()
+	defer b.credentialProvidersLock.Unlock()
+	if provider, ok := b.credentialProviders[key]; ok {
+		return provider
+	}
+	provider := build()
+	b.credentialProviders[key] = provider
+	return provider
+}
+
+// resetCredentialProviders drops every cached provider, so that none outlives
+// the config it was built for. Those still in use are built again.
+func (b *backend) resetCredentialProviders() {
+	b.credentialProvidersLock.Lock()
+	defer b.credentialProvidersLock.Unlock()
+	b.credentialProviders = make(map[string]*clients.CachedProvider)
+}
@@ -0,0 +1,218 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	leaseCountPath      = "lease_count/"
+	leaseCountMountPath = leaseCountPath + "mount"
+	leaseCountRolePath  = leaseCountPath + "role/"
+
+	// issueRateWindow is the period issue_rate_limit applies to.
+	issueRateWindow = time.Minute
+
+	// leaseCountedKey marks, in a lease's internal data, a lease that was
+	// counted when issued and so must be released when revoked.
+	leaseCountedKey = "counted"
+)
+
+// leaseCount tracks the unrevoked leases of a role or of the mount, and when
+// recent credentials were issued while a rate limit applies.
+type leaseCount struct {
+	Active       int         `json:"active"`
+	RecentIssues []time.Time `json:"recent_issues"`
+}
+
+// leaseLimitError reports a lease refused because a limit was reached, which is
+// the requester's to wait out rather than a failure of the backend.
+type leaseLimitError string
+
+func (e leaseLimitError) Error() string {
+	return string(e)
+}
+
+// admit counts a new lease, or fails if that would exceed the limits.
+func (c *leaseCount) admit(now time.Time, limits leaseLimits, scope string) error {
+	recent := c.RecentIssues[:0]
+	for _, issued := range c.RecentIssues {
+		if now.Sub(issued) < issueRateWindow {
+			recent = append(recent, issued)
+		}
+	}
+	c.RecentIssues = recent
+	if limits.MaxConcurrentLeases > 0 && c.Active >= limits.MaxConcurrentLeases {
+		return leaseLimitError(fmt.Sprintf("%s has reached its max_concurrent_leases of %d", scope, limits.MaxConcurrentLeases))
+	}
+	if limits.IssueRateLimit > 0 && len(c.RecentIssues) >= limits.IssueRateLimit {
+		return leaseLimitError(fmt.Sprintf("%s has reached its issue_rate_limit of %d per minute", scope, limits.IssueRateLimit))
+	}
+	c.Active++
+	if limits.IssueRateLimit > 0 {
+		c.RecentIssues = append(c.RecentIssues, now)
+	}
+	return nil
+}
+
+// reserveLease counts a lease about to be issued for the role against the
+// limits of the role and of the mount, failing with a leaseLimitError if
+// either is reached. Nothing is counted, and counted is false, when neither
+// has limits.
+func (b *backend) reserveLease(ctx context.Context, s logical.Storage, roleName string, role *roleEntry) (counted bool, err error) {
+	mountLimits, err := readLeaseLimits(ctx, s)
+	if err != nil {
+		return false, err
+	}
+	roleLimits := role.leaseLimits()
+	if *mountLimits == (leaseLimits{}) && roleLimits == (leaseLimits{}) {
+		return false, nil
+	}
+
+	b.leaseCountLock.Lock()
+	defer b.leaseCountLock.Unlock()
+
+	now := time.Now().UTC()
+	mountCount, err := readLeaseCount(ctx, s, leaseCountMountPath)
+	if err != nil {
+		return false, err
+	}
+	if err := mountCount.admit(now, *mountLimits, "mount"); err != nil {
+		return false, err
+	}
+	roleCount, err := readLeaseCount(ctx, s, leaseCountRolePath+roleName)
+	if err != nil {
+		return false, err
+	}
+	if err := roleCount.admit(now, roleLimits, "role "+roleName); err != nil {
+		return false, err
+	}
+	if err := writeLeaseCount(ctx, s, leaseCountMountPath, mountCount); err != nil {
+		return false, err
+	}
+	if err := writeLeaseCount(ctx, s, leaseCountRolePath+roleName, roleCount); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseLease stops counting a lease of the role once it is revoked, or once
+// issuing it failed. Revoked leases are only released if leaseCountedKey is
+// set on them.
+func (b *backend) releaseLease(ctx context.Context, s logical.Storage, roleName string) error {
+	b.leaseCountLock.Lock()
+	defer b.leaseCountLock.Unlock()
+
+	for _, key := range []string{leaseCountMountPath, leaseCountRolePath + roleName} {
+		count, err := readLeaseCount(ctx, s, key)
+		if err != nil {
+			return err
+		}
+		if count.Active == 0 {
+			continue
+		}
+		count.Active--
+		if err := writeLeaseCount(ctx, s, key, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recountLeases replaces the active counts with the leases in the issued index,
+// so counts left behind by leases that were never counted, or whose release
+// failed, don't block or admit leases forever. A renewed STS lease is indexed
+// once per renewal under the same session name and is counted once. A lease
+// being issued while the recount runs is missed until the next one.
+func (b *backend) recountLeases(ctx context.Context, s logical.Storage) error {
+	secretIds, err := s.List(ctx, issuedPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	active := make(map[string]int)
+	sessions := make(map[string]bool)
+	total := 0
+	for _, secretId := range secretIds {
+		issued, err := readIssued(ctx, s, secretId)
+		if err != nil {
+			return err
+		}
+		if issued == nil {
+			continue
+		}
+		if issued.RoleType == roleTypeSTS.String() {
+			if !now.Before(issued.Expiration) {
+				continue
+			}
+			session := issued.RoleARN + "/" + issued.RoleSessionName
+			if sessions[session] {
+				continue
+			}
+			sessions[session] = true
+		}
+		active[issued.RoleName]++
+		total++
+	}
+
+	b.leaseCountLock.Lock()
+	defer b.leaseCountLock.Unlock()
+
+	roleNames, err := s.List(ctx, leaseCountRolePath)
+	if err != nil {
+		return err
+	}
+	for _, roleName := range roleNames {
+		if _, ok := active[roleName]; !ok {
+			active[roleName] = 0
+		}
+	}
+	if err := recountLease(ctx, s, leaseCountMountPath, total); err != nil {
+		return err
+	}
+	for roleName, count := range active {
+		if err := recountLease(ctx, s, leaseCountRolePath+roleName, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recountLease sets the active count stored at key, writing only if it changed.
+func recountLease(ctx context.Context, s logical.Storage, key string, active int) error {
+	count, err := readLeaseCount(ctx, s, key)
+	if err != nil {
+		return err
+	}
+	if count.Active == active {
+		return nil
+	}
+	count.Active = active
+	return writeLeaseCount(ctx, s, key, count)
+}
+
+func readLeaseCount(ctx context.Context, s logical.Storage, key string) (*leaseCount, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	count := &leaseCount{}
+	if entry == nil {
+		return count, nil
+	}
+	if err := entry.DecodeJSON(count); err != nil {
+		return nil, err
+	}
+	return count, nil
+}
+
+func writeLeaseCount(ctx context.Context, s logical.Storage, key string, count *leaseCount) error {
+	entry, err := logical.StorageEntryJSON(key, count)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
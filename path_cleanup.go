@@ -0,0 +1,319 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/spf13/cast"
+)
+
+// cleanupPageSize is the page size used to list a user's policies and groups.
+const cleanupPageSize = 200
+
+func pathCleanupUser(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "cleanup/user$",
+		Fields: map[string]*framework.FieldSchema{
+			"username": {
+				Type:        framework.TypeString,
+				Description: "The name of the CAM user to delete.",
+			},
+			"uin": {
+				Type:        framework.TypeString,
+				Description: "The UIN of the CAM user to delete.",
+			},
+			"account": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "The named account the user is in. Defaults to the credentials in config.",
+			},
+			"force": {
+				Type:        framework.TypeBool,
+				Description: `If true, delete the user even if this backend has no record of creating it.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathCleanupUser,
+			},
+		},
+		HelpSynopsis:    pathCleanupUserHelpSyn,
+		HelpDescription: pathCleanupUserHelpDesc,
+	}
+}
+
+// camUser identifies a CAM user; Uid is needed to remove it from groups.
+type camUser struct {
+	Name string
+	Uin  uint64
+	Uid  uint64
+}
+
+func (b *backend) pathCleanupUser(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	userName := data.Get("username").(string)
+	uin := data.Get("uin").(string)
+	if (userName == "") == (uin == "") {
+		return nil, fmt.Errorf("exactly one of username or uin is required")
+	}
+	account := data.Get("account").(string)
+	client, err := b.accountCAMClient(ctx, req.Storage, account)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := findCAMUser(client, userName, uin)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return logical.ErrorResponse("no CAM user matches the given username or uin"), nil
+	}
+	if !data.Get("force").(bool) {
+		managed, err := b.isManagedUser(ctx, req.Storage, account, user)
+		if err != nil {
+			return nil, err
+		}
+		if !managed {
+			return logical.ErrorResponse(fmt.Sprintf("CAM user %s was not issued by this backend, "+
+				"set force to delete it anyway", user.Name)), nil
+		}
+	}
+	cleaned, err := cleanupCAMUser(client, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.forgetCAMUser(ctx, req.Storage, account, user); err != nil {
+		return nil, err
+	}
+	return &logical.Response{
+		Data: cleaned,
+	}, nil
+}
+
+// findCAMUser looks a user up by name, or by UIN among all the users of the
+// account. It returns nil if there is no such user.
+func findCAMUser(client *clients.CAMClient, userName, uin string) (*camUser, error) {
+	if userName != "" {
+		resp, err := client.GetUser(userName)
+		if clients.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &camUser{
+			Name: *resp.Response.Name,
+			Uin:  *resp.Response.Uin,
+			Uid:  *resp.Response.Uid,
+		}, nil
+	}
+	resp, err := client.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range resp.Response.Data {
+		if info.Uin != nil && cast.ToString(*info.Uin) == uin {
+			return &camUser{
+				Name: *info.Name,
+				Uin:  *info.Uin,
+				Uid:  *info.Uid,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// cleanupCAMUser deletes the user's access keys, detaches its policies,
+// removes it from its groups and deletes it, as listed by CAM rather than as
+// recorded in a lease. Only the inline policies created for the user are
+// deleted; other policies may be shared and are just detached.
+func cleanupCAMUser(client *clients.CAMClient, user *camUser) (map[string]interface{}, error) {
+	keysResp, err := client.ListAccessKeys(&user.Uin)
+	if err != nil {
+		return nil, err
+	}
+	var policyIds []uint64
+	var policyNames []string
+	for page := uint64(1); ; page++ {
+		resp, err := client.ListAttachedUserPolicies(&user.Uin, page, cleanupPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range resp.Response.List {
+			policyIds = append(policyIds, *policy.PolicyId)
+			policyNames = append(policyNames, *policy.PolicyName)
+		}
+		if len(resp.Response.List) < cleanupPageSize || uint64(len(policyIds)) >= *resp.Response.TotalNum {
+			break
+		}
+	}
+	var groupIds []uint64
+	for page := uint64(1); ; page++ {
+		resp, err := client.ListGroupsForUser(&user.Uin, page, cleanupPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range resp.Response.GroupInfo {
+			groupIds = append(groupIds, *group.GroupId)
+		}
+		if len(resp.Response.GroupInfo) < cleanupPageSize || uint64(len(groupIds)) >= *resp.Response.TotalNum {
+			break
+		}
+	}
+
+	apiErrs := &multierror.Error{}
+	accessKeyIds := make([]string, 0, len(keysResp.Response.AccessKeys))
+	for _, key := range keysResp.Response.AccessKeys {
+		accessKeyIds = append(accessKeyIds, *key.AccessKeyId)
+		if err := clients.IgnoreNotFound(client.DeleteAccessKey(key.AccessKeyId, &user.Uin)); err != nil {
+			apiErrs = multierror.Append(apiErrs, err)
+		}
+	}
+	var deletedPolicyIds []uint64
+	var lock sync.Mutex
+	if err := forEachPolicy(len(policyIds), func(i int) error {
+		if err := clients.IgnoreNotFound(client.DetachUserPolicy(&policyIds[i], &user.Uin)); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(policyNames[i], user.Name+"-") {
+			return nil
+		}
+		if err := clients.IgnoreNotFound(client.DeletePolicy([]*uint64{&policyIds[i]})); err != nil {
+			return err
+		}
+		lock.Lock()
+		deletedPolicyIds = append(deletedPolicyIds, policyIds[i])
+		lock.Unlock()
+		return nil
+	}); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
+	}
+	for i := range groupIds {
+		if err := clients.IgnoreNotFound(client.RemoveUserFromGroup(&user.Uid, &groupIds[i])); err != nil {
+			apiErrs = multierror.Append(apiErrs, err)
+		}
+	}
+	// The user can't be deleted while anything above is left.
+	if err := apiErrs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	if err := clients.IgnoreNotFound(client.DeleteUser(&user.Name)); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"username":            user.Name,
+		"uin":                 cast.ToString(user.Uin),
+		"access_key_ids":      accessKeyIds,
+		"detached_policy_ids": policyIds,
+		"deleted_policy_ids":  deletedPolicyIds,
+		"removed_group_ids":   groupIds,
+	}, nil
+}
+
+// isManagedUser reports whether this backend has a record of creating the
+// user: it has issued credentials or is in a pool. Names are not trusted, any
+// user could be given one that looks generated.
+func (b *backend) isManagedUser(ctx context.Context, s logical.Storage, account string, user *camUser) (bool, error) {
+	uin := cast.ToString(user.Uin)
+	secretIds, err := s.List(ctx, issuedPath)
+	if err != nil {
+		return false, err
+	}
+	for _, secretId := range secretIds {
+		issued, err := readIssued(ctx, s, secretId)
+		if err != nil {
+			return false, err
+		}
+		if issued != nil && issued.RoleType == roleTypeCAM.String() && issued.Account == account && issued.Uin == uin {
+			return true, nil
+		}
+	}
+
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+	pooledRoles, err := s.List(ctx, poolPath)
+	if err != nil {
+		return false, err
+	}
+	for _, pooledRole := range pooledRoles {
+		member, err := readPoolMember(ctx, s, poolPath+pooledRole+user.Name)
+		if err != nil {
+			return false, err
+		}
+		if member != nil && member.Account == account {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// forgetCAMUser marks the issued credentials of a deleted user as revoked, so
+// their leases can be revoked without calling CAM, and drops the user from the
+// pools it may be in.
+func (b *backend) forgetCAMUser(ctx context.Context, s logical.Storage, account string, user *camUser) error {
+	uin := cast.ToString(user.Uin)
+	secretIds, err := s.List(ctx, issuedPath)
+	if err != nil {
+		return err
+	}
+	for _, secretId := range secretIds {
+		issued, err := readIssued(ctx, s, secretId)
+		if err != nil {
+			return err
+		}
+		if issued == nil || issued.RoleType != roleTypeCAM.String() || issued.Account != account ||
+			issued.Uin != uin || !issued.RevokedAt.IsZero() {
+			continue
+		}
+		issued.RevokedAt = time.Now().UTC()
+		if err := writeIssued(ctx, s, issued); err != nil {
+			return err
+		}
+	}
+
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+	pooledRoles, err := s.List(ctx, poolPath)
+	if err != nil {
+		return err
+	}
+	for _, pooledRole := range pooledRoles {
+		key := poolPath + pooledRole + user.Name
+		member, err := readPoolMember(ctx, s, key)
+		if err != nil {
+			return err
+		}
+		if member == nil || member.Account != account {
+			continue
+		}
+		if err := s.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const pathCleanupUserHelpSyn = `
+Delete a CAM user created by this backend, with everything CAM lists for it.
+`
+
+const pathCleanupUserHelpDesc = `
+Given a username or UIN, lists the user's access keys, attached policies and
+groups from CAM, deletes the keys, detaches the policies, removes the user from
+its groups and deletes the user, whether or not a lease for it still exists.
+Use it when a lease can't be revoked because its internal data is incomplete.
+
+Policies named after the user, which this backend creates for inline policies,
+are deleted; other policies are only detached. The user's issued credentials
+are marked as revoked and it is dropped from any pool.
+
+Users without issued credentials or pool membership are only deleted with
+force set, whatever their name.
+`
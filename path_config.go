@@ -2,36 +2,238 @@ package tencentcloud
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/pluginidentityutil"
+	"github.com/hashicorp/vault/sdk/helper/pluginutil"
 	"github.com/hashicorp/vault/sdk/logical"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	configStoragePath = "config"
 	secretId          = "secret_id"
 	secretKey         = "secret_key"
+	region            = "region"
+	camEndpoint       = "cam_endpoint"
+	stsEndpoint       = "sts_endpoint"
+
+	roleARN               = "role_arn"
+	identityProviderId    = "identity_provider_id"
+	identityTokenAudience = "identity_token_audience"
+	identityTokenTTL      = "identity_token_ttl"
+
+	credentialSource = "credential_source"
+	cvmRoleName      = "cvm_role_name"
+
+	skipVerification  = "skip_verification"
+	verifyPermissions = "verify_permissions"
+	accountUin        = "account_uin"
+	principalARN      = "principal_arn"
 )
 
 type credConfig struct {
-	SecretId  string `json:"secret_id"`
-	SecretKey string `json:"secret_key"`
+	SecretId    string `json:"secret_id"`
+	SecretKey   string `json:"secret_key"`
+	Region      string `json:"region"`
+	CAMEndpoint string `json:"cam_endpoint"`
+	STSEndpoint string `json:"sts_endpoint"`
+
+	// Set to authenticate with a plugin identity token instead of a secret key.
+	RoleARN               string        `json:"role_arn"`
+	IdentityProviderId    string        `json:"identity_provider_id"`
+	IdentityTokenAudience string        `json:"identity_token_audience"`
+	IdentityTokenTTL      time.Duration `json:"identity_token_ttl"`
+
+	// Set to "cvm_role" to use only the role bound to the CVM instance.
+	CredentialSource string `json:"credential_source"`
+	CVMRoleName      string `json:"cvm_role_name"`
+
+	// Who the credentials belong to, as returned by GetCallerIdentity when the
+	// config was written.
+	AccountUin   string `json:"account_uin"`
+	PrincipalARN string `json:"principal_arn"`
+}
+
+func (b *backend) camConfig(c *credConfig) *clients.Configuration {
+	return b.clientConfig(c, c.CAMEndpoint)
+}
+
+func (b *backend) stsConfig(c *credConfig) *clients.Configuration {
+	return b.clientConfig(c, c.STSEndpoint)
+}
+
+func (b *backend) clientConfig(c *credConfig, endpoint string) *clients.Configuration {
+	config := &clients.Configuration{
+		SecretId:  c.SecretId,
+		SecretKey: c.SecretKey,
+		Region:    c.Region,
+		Endpoint:  endpoint,
+	}
+	if provider := b.credentialProvider(c); provider != nil {
+		config.Provider = provider
+	}
+	return config
+}
+
+// credConfigFields are shared by "config" and "config/account/<name>".
+func credConfigFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		secretId: {
+			Type:        framework.TypeString,
+			Description: "Secret Id with appropriate permissions.",
+		},
+		secretKey: {
+			Type:        framework.TypeString,
+			Description: "Secret Key with appropriate permissions.",
+		},
+		region: {
+			Type:        framework.TypeString,
+			Description: "Region to send CAM and STS requests to. Defaults to ap-ashburn.",
+		},
+		camEndpoint: {
+			Type:        framework.TypeString,
+			Description: "Endpoint to use for CAM requests instead of cam.tencentcloudapi.com.",
+		},
+		stsEndpoint: {
+			Type:        framework.TypeString,
+			Description: "Endpoint to use for STS requests instead of sts.tencentcloudapi.com.",
+		},
+		roleARN: {
+			Type: framework.TypeString,
+			Description: `Role to assume with a plugin identity token through STS
+AssumeRoleWithWebIdentity, instead of using a secret id and key.`,
+		},
+		identityProviderId: {
+			Type:        framework.TypeString,
+			Description: "Name of the OIDC identity provider in CAM that trusts Vault's identity tokens.",
+		},
+		identityTokenAudience: {
+			Type:        framework.TypeString,
+			Description: "Audience of the plugin identity token.",
+		},
+		identityTokenTTL: {
+			Type:        framework.TypeDurationSecond,
+			Description: "Lifetime of the plugin identity token. Defaults to 1 hour.",
+		},
+		credentialSource: {
+			Type: framework.TypeString,
+			Description: `Set to "cvm_role" to use only the temporary credentials of cvm_role_name,
+the role bound to the CVM instance Vault runs on.`,
+		},
+		cvmRoleName: {
+			Type:        framework.TypeString,
+			Description: `Name of the CVM instance role used when credential_source is "cvm_role".`,
+		},
+		skipVerification: {
+			Type: framework.TypeBool,
+			Description: `If true, the credentials are stored without calling STS GetCallerIdentity
+to check them, for setups that can't reach Tencent Cloud.`,
+		},
+		verifyPermissions: {
+			Type: framework.TypeBool,
+			Description: `If true, also run the CAM checks of config/check: create and delete a
+throwaway CAM user, deny-all policy and access key. The write fails if CAM denies any of them.`,
+		},
+	}
+}
+
+// updateCredConfig copies the fields set on the request to creds.
+func updateCredConfig(creds *credConfig, data *framework.FieldData) {
+	if secretIdIfc, ok := data.GetOk(secretId); ok {
+		creds.SecretId = secretIdIfc.(string)
+	}
+	if secretKeyIfc, ok := data.GetOk(secretKey); ok {
+		creds.SecretKey = secretKeyIfc.(string)
+	}
+	if regionIfc, ok := data.GetOk(region); ok {
+		creds.Region = regionIfc.(string)
+	}
+	if camEndpointIfc, ok := data.GetOk(camEndpoint); ok {
+		creds.CAMEndpoint = camEndpointIfc.(string)
+	}
+	if stsEndpointIfc, ok := data.GetOk(stsEndpoint); ok {
+		creds.STSEndpoint = stsEndpointIfc.(string)
+	}
+	if roleARNIfc, ok := data.GetOk(roleARN); ok {
+		creds.RoleARN = roleARNIfc.(string)
+	}
+	if identityProviderIdIfc, ok := data.GetOk(identityProviderId); ok {
+		creds.IdentityProviderId = identityProviderIdIfc.(string)
+	}
+	if identityTokenAudienceIfc, ok := data.GetOk(identityTokenAudience); ok {
+		creds.IdentityTokenAudience = identityTokenAudienceIfc.(string)
+	}
+	if identityTokenTTLIfc, ok := data.GetOk(identityTokenTTL); ok {
+		creds.IdentityTokenTTL = time.Duration(identityTokenTTLIfc.(int)) * time.Second
+	}
+	if credentialSourceIfc, ok := data.GetOk(credentialSource); ok {
+		creds.CredentialSource = credentialSourceIfc.(string)
+	}
+	if cvmRoleNameIfc, ok := data.GetOk(cvmRoleName); ok {
+		creds.CVMRoleName = cvmRoleNameIfc.(string)
+	}
+}
+
+// validateCredConfig checks that creds use exactly one of a secret key, a
+// plugin identity token or the CVM instance role, and that this Vault issues
+// plugin identity tokens.
+func (b *backend) validateCredConfig(ctx context.Context, creds *credConfig) error {
+	switch creds.CredentialSource {
+	case "":
+		if creds.CVMRoleName != "" {
+			return fmt.Errorf("%s requires %s of %q", cvmRoleName, credentialSource, credentialSourceCVMRole)
+		}
+	case credentialSourceCVMRole:
+		if creds.CVMRoleName == "" {
+			return fmt.Errorf("%s is required for %s %q", cvmRoleName, credentialSource, credentialSourceCVMRole)
+		}
+		if creds.SecretId != "" || creds.SecretKey != "" || creds.RoleARN != "" {
+			return fmt.Errorf("%s %q can't be used with %s, %s or %s", credentialSource,
+				credentialSourceCVMRole, secretId, secretKey, roleARN)
+		}
+	default:
+		return fmt.Errorf("unknown %s: %s", credentialSource, creds.CredentialSource)
+	}
+	if creds.RoleARN == "" {
+		if creds.IdentityProviderId != "" || creds.IdentityTokenAudience != "" || creds.IdentityTokenTTL != 0 {
+			return fmt.Errorf("%s, %s and %s require %s",
+				identityProviderId, identityTokenAudience, identityTokenTTL, roleARN)
+		}
+		return nil
+	}
+	if creds.SecretId != "" || creds.SecretKey != "" {
+		return fmt.Errorf("%s can't be used with %s and %s", roleARN, secretId, secretKey)
+	}
+	if creds.IdentityProviderId == "" || creds.IdentityTokenAudience == "" {
+		return fmt.Errorf("%s requires %s and %s", roleARN, identityProviderId, identityTokenAudience)
+	}
+	// Other errors may clear up, such as a missing identity key, so only the
+	// lack of support fails the write. Over gRPC the error arrives as text,
+	// and Vault servers older than 1.16 don't implement the call at all.
+	_, err := b.System().GenerateIdentityToken(ctx, &pluginutil.IdentityTokenRequest{
+		Audience: creds.IdentityTokenAudience,
+		TTL:      creds.IdentityTokenTTL,
+	})
+	if err != nil && (errors.Is(err, pluginidentityutil.ErrPluginWorkloadIdentityUnsupported) ||
+		strings.Contains(err.Error(), pluginidentityutil.ErrPluginWorkloadIdentityUnsupported.Error()) ||
+		status.Code(err) == codes.Unimplemented) {
+		return fmt.Errorf("%s requires plugin identity tokens: %w", roleARN, err)
+	}
+	return nil
 }
 
 func pathConfig(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "config",
-		Fields: map[string]*framework.FieldSchema{
-			secretId: {
-				Type:        framework.TypeString,
-				Description: "Secret Id with appropriate permissions.",
-			},
-			secretKey: {
-				Type:        framework.TypeString,
-				Description: "Secret Key with appropriate permissions.",
-			},
-		},
+		Fields:  credConfigFields(),
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.CreateOperation: &framework.PathOperation{
 				Callback: b.pathConfigWrite,
@@ -65,17 +267,20 @@ func (b *backend) pathConfigWrite(ctx context.Context,
 		creds = new(credConfig)
 	}
 
-	if secretIdIfc, ok := data.GetOk(secretId); ok {
-		creds.SecretId = secretIdIfc.(string)
+	updateCredConfig(creds, data)
+	if err := b.validateCredConfig(ctx, creds); err != nil {
+		return nil, err
 	}
-	if secretKeyIfc, ok := data.GetOk(secretKey); ok {
-		creds.SecretKey = secretKeyIfc.(string)
+	resp, err := b.verifyCredConfig(creds, data)
+	if err != nil {
+		return nil, err
 	}
 	err = writeCredConfig(ctx, creds, req.Storage)
 	if err != nil {
 		return nil, err
 	}
-	return nil, nil
+	b.resetClients("")
+	return resp, nil
 }
 
 func (b *backend) pathConfigRead(ctx context.Context,
@@ -87,12 +292,102 @@ func (b *backend) pathConfigRead(ctx context.Context,
 	if creds == nil {
 		return nil, nil
 	}
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: map[string]interface{}{
-			secretId:  creds.SecretId,
-			secretKey: creds.SecretKey,
+			secretId:    creds.SecretId,
+			secretKey:   creds.SecretKey,
+			region:      creds.Region,
+			camEndpoint: creds.CAMEndpoint,
+			stsEndpoint: creds.STSEndpoint,
+
+			roleARN:               creds.RoleARN,
+			identityProviderId:    creds.IdentityProviderId,
+			identityTokenAudience: creds.IdentityTokenAudience,
+			identityTokenTTL:      int64(creds.IdentityTokenTTL / time.Second),
+
+			credentialSource: creds.CredentialSource,
+			cvmRoleName:      creds.CVMRoleName,
+
+			accountUin:   creds.AccountUin,
+			principalARN: creds.PrincipalARN,
 		},
-	}, nil
+	}
+	b.addCredentialStatus(resp, creds)
+	return resp, nil
+}
+
+// verifyCredConfig checks creds with STS GetCallerIdentity unless the request
+// skips verification, and records the account and principal they belong to.
+// It returns a response holding any warnings, or nil.
+func (b *backend) verifyCredConfig(creds *credConfig, data *framework.FieldData) (*logical.Response, error) {
+	creds.AccountUin = ""
+	creds.PrincipalARN = ""
+	if data.Get(skipVerification).(bool) {
+		return nil, nil
+	}
+	stsClient, err := clients.NewSTSClient(b.profile, b.stsConfig(creds))
+	if err != nil {
+		return nil, err
+	}
+	identity, err := stsClient.GetCallerIdentity()
+	if err != nil {
+		return nil, fmt.Errorf("unable to verify credentials: %w", err)
+	}
+	creds.AccountUin = *identity.Response.AccountId
+	creds.PrincipalARN = *identity.Response.Arn
+	if !data.Get(verifyPermissions).(bool) {
+		return nil, nil
+	}
+	camClient, err := clients.NewCAMClient(b.profile, b.camConfig(creds))
+	if err != nil {
+		return nil, err
+	}
+	check := &configCheck{results: make(map[string]map[string]interface{})}
+	leftovers := b.checkCAM(camClient, check)
+	resp := &logical.Response{}
+	for _, leftover := range leftovers {
+		resp.AddWarning(fmt.Sprintf("unable to clean up %s, it must be deleted by hand", leftover))
+	}
+	if len(check.denied) > 0 {
+		err := fmt.Errorf("%s may not call CAM %s", creds.PrincipalARN, strings.Join(check.denied, ", "))
+		if len(leftovers) > 0 {
+			err = fmt.Errorf("%w; unable to clean up %s, it must be deleted by hand", err, strings.Join(leftovers, " and "))
+		}
+		return nil, err
+	}
+	actions := make([]string, 0, len(check.results))
+	for action := range check.results {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		if result := check.results[action]; result["status"] == checkStatusFailed {
+			resp.AddWarning(fmt.Sprintf("%s may not be able to call CAM %s: %s", creds.PrincipalARN, action, result["error"]))
+		}
+	}
+	if len(resp.Warnings) == 0 {
+		return nil, nil
+	}
+	return resp, nil
+}
+
+// addCredentialStatus reports which temporary credentials a config is using
+// and when they expire, fetching them if none have been fetched yet.
+func (b *backend) addCredentialStatus(resp *logical.Response, creds *credConfig) {
+	provider := b.credentialProvider(creds)
+	if provider == nil {
+		return
+	}
+	if _, err := provider.GetCredential(); err != nil {
+		resp.AddWarning(fmt.Sprintf("unable to get temporary credentials: %s", err))
+		return
+	}
+	if creds.CredentialSource == credentialSourceCVMRole {
+		resp.Data["active_role"] = creds.CVMRoleName
+	} else {
+		resp.Data["active_role"] = creds.RoleARN
+	}
+	resp.Data["credential_expiration"] = provider.Expiration().UTC().Format(time.RFC3339)
 }
 
 func (b *backend) pathConfigDelete(ctx context.Context,
@@ -100,6 +395,7 @@ func (b *backend) pathConfigDelete(ctx context.Context,
 	if err := req.Storage.Delete(ctx, configStoragePath); err != nil {
 		return nil, err
 	}
+	b.resetClients("")
 	return nil, nil
 }
 
@@ -128,6 +424,34 @@ func readCredConfig(ctx context.Context, storage logical.Storage) (*credConfig,
 	return creds, nil
 }
 
+// readAccountConfig returns the credentials of the named account, or those in
+// "config" when account is empty.
+func readAccountConfig(ctx context.Context, storage logical.Storage, account string) (*credConfig, error) {
+	if account == "" {
+		return readCredConfig(ctx, storage)
+	}
+	entry, err := storage.Get(ctx, accountStoragePath+account)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	creds := &credConfig{}
+	if err = entry.DecodeJSON(creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// credsNotConfigured describes why no credentials were found for an account.
+func credsNotConfigured(account string) string {
+	if account == "" {
+		return "no credentials are configured"
+	}
+	return fmt.Sprintf("account %s is not configured", account)
+}
+
 func writeCredConfig(ctx context.Context, config *credConfig, s logical.Storage) error {
 	entry, err := logical.StorageEntryJSON(configStoragePath, config)
 
@@ -0,0 +1,188 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const accountStoragePath = "config/account/"
+
+func pathConfigAccount(b *backend) *framework.Path {
+	fields := credConfigFields()
+	fields["name"] = &framework.FieldSchema{
+		Type:        framework.TypeLowerCaseString,
+		Description: "The name of the account.",
+	}
+	return &framework.Path{
+		Pattern: "config/account/" + framework.GenericNameRegex("name"),
+		Fields:  fields,
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: b.pathConfigAccountWrite,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigAccountWrite,
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathConfigAccountRead,
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathConfigAccountDelete,
+			},
+		},
+		ExistenceCheck:  b.pathConfigAccountExistenceCheck,
+		HelpSynopsis:    pathConfigAccountHelpSyn,
+		HelpDescription: pathConfigAccountHelpDesc,
+	}
+}
+
+func pathListConfigAccounts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/account/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathConfigAccountsList,
+			},
+		},
+		HelpSynopsis:    pathListConfigAccountsHelpSyn,
+		HelpDescription: pathListConfigAccountsHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigAccountWrite(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	creds, err := readAccountConfig(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		if req.Operation == logical.UpdateOperation {
+			return nil, fmt.Errorf("account not found during update operation")
+		}
+		creds = new(credConfig)
+	}
+	updateCredConfig(creds, data)
+	if err := b.validateCredConfig(ctx, creds); err != nil {
+		return nil, err
+	}
+	if creds.RoleARN == "" && creds.CredentialSource == "" && (creds.SecretId == "" || creds.SecretKey == "") {
+		return nil, fmt.Errorf("secret_id and secret_key, role_arn or credential_source are required")
+	}
+	resp, err := b.verifyCredConfig(creds, data)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := logical.StorageEntryJSON(accountStoragePath+name, creds)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+	b.resetClients(name)
+	return resp, nil
+}
+
+func (b *backend) pathConfigAccountRead(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	creds, err := readAccountConfig(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		return nil, nil
+	}
+	// Unlike "config", the secret key of an account is never returned.
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			secretId:    creds.SecretId,
+			region:      creds.Region,
+			camEndpoint: creds.CAMEndpoint,
+			stsEndpoint: creds.STSEndpoint,
+
+			roleARN:               creds.RoleARN,
+			identityProviderId:    creds.IdentityProviderId,
+			identityTokenAudience: creds.IdentityTokenAudience,
+			identityTokenTTL:      int64(creds.IdentityTokenTTL / time.Second),
+
+			credentialSource: creds.CredentialSource,
+			cvmRoleName:      creds.CVMRoleName,
+
+			accountUin:   creds.AccountUin,
+			principalARN: creds.PrincipalARN,
+		},
+	}
+	b.addCredentialStatus(resp, creds)
+	return resp, nil
+}
+
+func (b *backend) pathConfigAccountDelete(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	roleNames, err := req.Storage.List(ctx, rolePath)
+	if err != nil {
+		return nil, err
+	}
+	var inUse []string
+	for _, roleName := range roleNames {
+		role, err := readRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil && role.Account == name {
+			inUse = append(inUse, roleName)
+		}
+	}
+	if len(inUse) > 0 {
+		sort.Strings(inUse)
+		return nil, fmt.Errorf("account %s is used by roles: %s", name, strings.Join(inUse, ", "))
+	}
+	if err := req.Storage.Delete(ctx, accountStoragePath+name); err != nil {
+		return nil, err
+	}
+	b.resetClients(name)
+	return nil, nil
+}
+
+func (b *backend) pathConfigAccountsList(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	names, err := req.Storage.List(ctx, accountStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(names), nil
+}
+
+func (b *backend) pathConfigAccountExistenceCheck(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (bool, error) {
+	creds, err := readAccountConfig(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return creds != nil, nil
+}
+
+const pathConfigAccountHelpSyn = `
+Configure the credentials, region and endpoints of a named account.
+`
+
+const pathConfigAccountHelpDesc = `
+Named accounts let one mount manage CAM users and assume roles in several
+Tencent Cloud accounts. A role uses the account named in its "account" field,
+or the credentials in "config" when it names none. The account is recorded on
+each lease, so leases are renewed and revoked with the credentials they were
+issued with. An account can't be deleted while roles still use it.
+`
+
+const pathListConfigAccountsHelpSyn = "List the names of the configured accounts."
+
+const pathListConfigAccountsHelpDesc = `
+Returns the names of the accounts configured under "config/account/".
+`
@@ -0,0 +1,191 @@
+package tencentcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	checkStatusOK      = "ok"
+	checkStatusFailed  = "failed"
+	checkStatusSkipped = "skipped"
+
+	checkSessionName = "vault-config-check"
+
+	// checkPolicyDocument denies everything, so the temporary user created by a
+	// check can do nothing even while the policy is attached.
+	checkPolicyDocument = `{"version":"2.0","statement":[{"effect":"deny","action":["*"],"resource":["*"]}]}`
+)
+
+func pathConfigCheck(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/check$",
+		Fields: map[string]*framework.FieldSchema{
+			"account": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "The named account to check. Defaults to the credentials in config.",
+			},
+			"role_arn": {
+				Type:        framework.TypeString,
+				Description: "A role to check AssumeRole against. AssumeRole is skipped if not set.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigCheck,
+			},
+		},
+		HelpSynopsis:    pathConfigCheckHelpSyn,
+		HelpDescription: pathConfigCheckHelpDesc,
+	}
+}
+
+// configCheck records the outcome of each API call made by a check, and which
+// actions Tencent Cloud denied outright.
+type configCheck struct {
+	results map[string]map[string]interface{}
+	denied  []string
+}
+
+func (c *configCheck) record(action string, err error) bool {
+	if err != nil {
+		if clients.IsUnauthorized(err) {
+			c.denied = append(c.denied, action)
+		}
+		c.results[action] = map[string]interface{}{
+			"status": checkStatusFailed,
+			"error":  err.Error(),
+		}
+		return false
+	}
+	c.results[action] = map[string]interface{}{
+		"status": checkStatusOK,
+	}
+	return true
+}
+
+func (c *configCheck) skip(reason string, actions ...string) {
+	for _, action := range actions {
+		c.results[action] = map[string]interface{}{
+			"status": checkStatusSkipped,
+			"error":  reason,
+		}
+	}
+}
+
+func (b *backend) pathConfigCheck(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	account := data.Get("account").(string)
+	roleARN := data.Get("role_arn").(string)
+	accountClient, err := b.clientsFor(ctx, req.Storage, account)
+	if err != nil {
+		return nil, err
+	}
+	if accountClient == nil {
+		return nil, errors.New(credsNotConfigured(account))
+	}
+
+	check := &configCheck{results: make(map[string]map[string]interface{})}
+	leftovers := b.checkCAM(accountClient.cam, check)
+	if roleARN == "" {
+		check.skip("role_arn was not given", "AssumeRole")
+	} else {
+		_, err := accountClient.sts.AssumeRole(checkSessionName, roleARN, "", 0)
+		check.record("AssumeRole", err)
+	}
+
+	permitted := true
+	for _, result := range check.results {
+		if result["status"] == checkStatusFailed {
+			permitted = false
+		}
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"account":       account,
+			"principal_arn": accountClient.creds.PrincipalARN,
+			"permitted":     permitted,
+			"checks":        check.results,
+		},
+	}
+	for _, leftover := range leftovers {
+		resp.AddWarning(fmt.Sprintf("unable to clean up %s, it must be deleted by hand", leftover))
+	}
+	return resp, nil
+}
+
+// checkCAM walks through the lifecycle of a CAM secret with a throwaway user
+// and a deny-all policy, undoing each step it got through. It returns the
+// resources it was unable to delete.
+func (b *backend) checkCAM(client *clients.CAMClient, check *configCheck) (leftovers []string) {
+	_, err := client.ListPolicies("", "All")
+	check.record("ListPolicies", err)
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	name := fmt.Sprintf("vault-config-check-%d-%d", time.Now().Unix(), r.Intn(10000))
+
+	createUserResp, err := client.AddUser(name)
+	userCreated := check.record("AddUser", err)
+	createPolicyResp, err := client.CreatePolicy(name, checkPolicyDocument)
+	policyCreated := check.record("CreatePolicy", err)
+
+	if !userCreated {
+		check.skip("AddUser failed", "AttachUserPolicy", "DetachUserPolicy",
+			"CreateAccessKey", "DeleteAccessKey", "DeleteUser")
+	}
+	if !policyCreated {
+		check.skip("CreatePolicy failed", "AttachUserPolicy", "DetachUserPolicy", "DeletePolicy")
+	}
+
+	if userCreated && policyCreated {
+		err := client.AttachUserPolicy(createPolicyResp.Response.PolicyId, createUserResp.Response.Uin)
+		if check.record("AttachUserPolicy", err) {
+			err := client.DetachUserPolicy(createPolicyResp.Response.PolicyId, createUserResp.Response.Uin)
+			check.record("DetachUserPolicy", err)
+		} else {
+			check.skip("AttachUserPolicy failed", "DetachUserPolicy")
+		}
+	}
+	if userCreated {
+		accessKeyResp, err := client.CreateAccessKey(createUserResp.Response.Uin)
+		if check.record("CreateAccessKey", err) {
+			err := client.DeleteAccessKey(accessKeyResp.Response.AccessKey.AccessKeyId, createUserResp.Response.Uin)
+			check.record("DeleteAccessKey", err)
+		} else {
+			check.skip("CreateAccessKey failed", "DeleteAccessKey")
+		}
+	}
+	if policyCreated {
+		err := client.DeletePolicy([]*uint64{createPolicyResp.Response.PolicyId})
+		if !check.record("DeletePolicy", err) {
+			leftovers = append(leftovers, "CAM policy "+name)
+		}
+	}
+	if userCreated {
+		err := client.DeleteUser(&name)
+		if !check.record("DeleteUser", err) {
+			leftovers = append(leftovers, "CAM user "+name)
+		}
+	}
+	return leftovers
+}
+
+const pathConfigCheckHelpSyn = `
+Check which Tencent Cloud APIs the configured credentials may call.
+`
+
+const pathConfigCheckHelpDesc = `
+This path calls every Tencent Cloud API the backend depends on and reports,
+for each, whether the call succeeded. To do so it creates a temporary CAM user
+and a deny-all CAM policy, attaches and detaches the policy, creates and
+deletes an access key for the user, and then deletes both. AssumeRole is only
+checked when a role_arn is given. Anything the check was unable to delete is
+returned as a warning.
+`
@@ -0,0 +1,128 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const limitsStoragePath = "config/limits"
+
+// leaseLimits bound how many leases a role, or the whole mount, may have.
+// Zero means no limit.
+type leaseLimits struct {
+	MaxConcurrentLeases int `json:"max_concurrent_leases"`
+	IssueRateLimit      int `json:"issue_rate_limit"`
+}
+
+func (l *leaseLimits) validate() error {
+	if l.MaxConcurrentLeases < 0 {
+		return fmt.Errorf("max_concurrent_leases must not be negative")
+	}
+	if l.IssueRateLimit < 0 {
+		return fmt.Errorf("issue_rate_limit must not be negative")
+	}
+	return nil
+}
+
+func pathConfigLimits(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/limits$",
+		Fields: map[string]*framework.FieldSchema{
+			"max_concurrent_leases": {
+				Type:        framework.TypeInt,
+				Description: "The most leases the mount may have at once, across all roles. 0 means no limit.",
+			},
+			"issue_rate_limit": {
+				Type:        framework.TypeInt,
+				Description: "The most credentials the mount may issue per minute, across all roles. 0 means no limit.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigLimitsWrite,
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathConfigLimitsRead,
+			},
+		},
+		HelpSynopsis:    pathConfigLimitsHelpSyn,
+		HelpDescription: pathConfigLimitsHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigLimitsWrite(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	limits, err := readLeaseLimits(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if raw, ok := data.GetOk("max_concurrent_leases"); ok {
+		limits.MaxConcurrentLeases = raw.(int)
+	}
+	if raw, ok := data.GetOk("issue_rate_limit"); ok {
+		limits.IssueRateLimit = raw.(int)
+	}
+	if err := limits.validate(); err != nil {
+		return nil, err
+	}
+	entry, err := logical.StorageEntryJSON(limitsStoragePath, limits)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (b *backend) pathConfigLimitsRead(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	limits, err := readLeaseLimits(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	count, err := readLeaseCount(ctx, req.Storage, leaseCountMountPath)
+	if err != nil {
+		return nil, err
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"max_concurrent_leases": limits.MaxConcurrentLeases,
+			"issue_rate_limit":      limits.IssueRateLimit,
+			"active_leases":         count.Active,
+		},
+	}, nil
+}
+
+// readLeaseLimits returns the limits of the mount, which are empty until
+// config/limits is written.
+func readLeaseLimits(ctx context.Context, s logical.Storage) (*leaseLimits, error) {
+	entry, err := s.Get(ctx, limitsStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	limits := &leaseLimits{}
+	if entry == nil {
+		return limits, nil
+	}
+	if err := entry.DecodeJSON(limits); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+const pathConfigLimitsHelpSyn = `
+Limit how many leases the mount may have and how fast it may issue them.
+`
+
+const pathConfigLimitsHelpDesc = `
+Reading creds fails before anything is created in Tencent Cloud once the mount
+has max_concurrent_leases unrevoked leases, or has issued issue_rate_limit
+credentials in the last minute. Roles can set their own, lower limits with the
+same parameters. Leases are only counted while there are limits, and are
+recounted from the issued index periodically. Reads also return the number of
+active leases.
+`
@@ -3,15 +3,21 @@ package tencentcloud
 import (
 	"container/list"
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	camLocal "github.com/hashicorp/vault-plugin-secrets-tencentcloud/sdk/tencentcloud/cam/v20190116"
 	cam "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
 	"math/rand"
+	"sync"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/spf13/cast"
 )
@@ -20,6 +26,10 @@ const credsPath = "creds/"
 
 const timeLayout = "2006-01-02T15:04:05Z"
 
+// policyConcurrency is the number of policies created, attached, detached or
+// deleted at the same time for one user.
+const policyConcurrency = 4
+
 func pathCreds(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: credsPath + framework.GenericNameRegex("name"),
@@ -34,6 +44,11 @@ func pathCreds(b *backend) *framework.Path {
 This improves the security of role assuming by preventing unauthorized use of the role when the role information is leaked or guessed.
 You're advised to enable external ID verification if you will allow a third-party platform to use the role to be created, or if the account and role information is easily accessible by other users.`,
 			},
+			"ttl": {
+				Type: framework.TypeDurationSecond,
+				Description: `Lifetime in seconds of the credentials, bounded by the role's max_ttl and the
+mount's max TTL. Defaults to the role's ttl for CAM roles and to the STS default for STS roles.`,
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
@@ -45,8 +60,8 @@ You're advised to enable external ID verification if you will allow a third-part
 	}
 }
 
-func checkData(roleName string, ctx context.Context, req *logical.Request) (
-	role *roleEntry, creds *credConfig, err error) {
+func (b *backend) checkData(roleName string, ctx context.Context, req *logical.Request) (
+	role *roleEntry, client *accountClients, err error) {
 	if roleName == "" {
 		return nil, nil, fmt.Errorf("name is required")
 	}
@@ -57,48 +72,92 @@ func checkData(roleName string, ctx context.Context, req *logical.Request) (
 	if role == nil {
 		return nil, nil, fmt.Errorf("role is nil")
 	}
-	creds, err = readCredConfig(ctx, req.Storage)
+	client, err = b.clientsFor(ctx, req.Storage, role.Account)
 	if err != nil {
 		return nil, nil, err
 	}
-	if creds == nil {
-		return nil, nil, fmt.Errorf("unable to create secret because no credentials are configured")
+	if client == nil {
+		return nil, nil, fmt.Errorf("unable to create secret because %s", credsNotConfigured(role.Account))
 	}
-	return role, creds, nil
+	return role, client, nil
 }
 
-func (b *backend) roleTypeSTSFunc(creds *credConfig, req *logical.Request,
-	role *roleEntry, roleName, externalId string) (*logical.Response, error) {
-	client, err := clients.NewSTSClient(b.profile, creds.SecretId, creds.SecretKey)
+func (b *backend) roleTypeSTSFunc(client *accountClients, req *logical.Request,
+	role *roleEntry, roleName, externalId string, ttl time.Duration) (*logical.Response, error) {
+	roleSessionName, err := generateRoleSessionName(req.DisplayName, roleName)
 	if err != nil {
 		return nil, err
 	}
-	assumeRoleResp, err := client.AssumeRole(generateRoleSessionName(req.DisplayName, roleName), role.RoleARN, externalId)
+	credsData, expiration, err := b.assumeRole(client, role.SourceRoleARNs, roleSessionName,
+		role.RoleARN, externalId, ttl)
 	if err != nil {
 		return nil, err
 	}
+	resp := b.Secret(secretType).Response(credsData, map[string]interface{}{
+		"role_type":         roleTypeSTS.String(),
+		"role_name":         roleName,
+		"role_version":      role.Version,
+		"role_arn":          role.RoleARN,
+		"source_role_arn":   role.SourceRoleARNs,
+		"account":           role.Account,
+		"role_session_name": roleSessionName,
+		"secret_id":         credsData["secret_id"],
+		"external_id":       externalId,
+		"ttl":               int64(ttl / time.Second),
+		"revoke_sessions":   role.STSRevokeSessions,
+	})
+	leaseTTL := expiration.Sub(time.Now())
+	resp.Secret.TTL = leaseTTL
+	resp.Secret.MaxTTL = leaseTTL
+	resp.Secret.Renewable = false
+	if role.STSRenewable {
+		_, resp.Secret.MaxTTL = b.effectiveLeaseTTLs(role)
+		resp.Secret.Renewable = true
+	}
+	return resp, nil
+}
+
+// assumeRole calls AssumeRole and returns the response data of an STS secret
+// along with the expiration of the temporary credentials. The source roles are
+// assumed first, in order, each with the credentials of the previous one.
+func (b *backend) assumeRole(accountClient *accountClients, sourceRoleARNs []string, roleSessionName, roleARN,
+	externalId string, ttl time.Duration) (map[string]interface{}, time.Time, error) {
+	client := accountClient.sts
+	config := b.stsConfig(accountClient.creds)
+	for _, sourceRoleARN := range sourceRoleARNs {
+		sourceResp, err := client.AssumeRole(roleSessionName, sourceRoleARN, "", 0)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("unable to assume source role %s: %w", sourceRoleARN, err)
+		}
+		config = &clients.Configuration{
+			SecretId:  *(sourceResp.Response.Credentials.TmpSecretId),
+			SecretKey: *(sourceResp.Response.Credentials.TmpSecretKey),
+			Token:     *(sourceResp.Response.Credentials.Token),
+			Region:    config.Region,
+			Endpoint:  config.Endpoint,
+		}
+		if client, err = clients.NewSTSClient(b.profile, config); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+	assumeRoleResp, err := client.AssumeRole(roleSessionName, roleARN, externalId, uint64(ttl/time.Second))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 	expiration, err := time.Parse(timeLayout, *(assumeRoleResp.Response.Expiration))
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
-	resp := b.Secret(secretType).Response(map[string]interface{}{
+	return map[string]interface{}{
 		"secret_id":  *(assumeRoleResp.Response.Credentials.TmpSecretId),
 		"secret_key": *(assumeRoleResp.Response.Credentials.TmpSecretKey),
 		"token":      *(assumeRoleResp.Response.Credentials.Token),
 		"expiration": expiration,
-	}, map[string]interface{}{
-		"role_type": roleTypeSTS.String(),
-	})
-	ttl := expiration.Sub(time.Now())
-	resp.Secret.TTL = ttl
-	resp.Secret.MaxTTL = ttl
-	resp.Secret.Renewable = false
-	return resp, nil
+	}, expiration, nil
 }
 
-func addUserFunc(req *logical.Request, roleName string, failList *list.List, client *clients.CAMClient) (
+func addUserFunc(userName string, failList *list.List, client *clients.CAMClient) (
 	createUserResp *cam.AddUserResponse, err error) {
-	userName := generateUsername(req.DisplayName, roleName)
 	failList.PushBack(&addUserFail{&userName})
 	createUserResp, err = client.AddUser(userName)
 	if err != nil {
@@ -110,104 +169,224 @@ func addUserFunc(req *logical.Request, roleName string, failList *list.List, cli
 func inlinePolicyFunc(createUserResp *cam.AddUserResponse,
 	role *roleEntry, failList *list.List, client *clients.CAMClient) (inlinePolicies []*remotePolicy, err error) {
 	inlinePolicies = make([]*remotePolicy, len(role.InlinePolicies))
-	for i, inlinePolicy := range role.InlinePolicies {
-		policyName := *createUserResp.Response.Name + "-" + inlinePolicy.UUID
+	var failListLock sync.Mutex
+	err = forEachPolicy(len(role.InlinePolicies), func(i int) error {
+		inlinePolicy := role.InlinePolicies[i]
+		policyName := inlinePolicyName(*createUserResp.Response.Name, inlinePolicy)
 		policyDoc, err := jsonutil.EncodeJSON(inlinePolicy.PolicyDocument)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		createPolicyResp, err := client.CreatePolicy(policyName, string(policyDoc))
 		if createPolicyResp != nil && createPolicyResp.Response != nil {
+			failListLock.Lock()
 			failList.PushBack(&createPolicyFail{createPolicyResp.Response.PolicyId})
+			failListLock.Unlock()
 		}
 		if err != nil {
-			return nil, err
+			return err
 		}
 		inlinePolicies[i] = &remotePolicy{
 			PolicyId: *(createPolicyResp.Response.PolicyId),
 		}
+		failListLock.Lock()
 		failList.PushBack(&attachUserPolicyFail{
 			createPolicyResp.Response.PolicyId,
 			createUserResp.Response.Uin})
-		if err := client.AttachUserPolicy(createPolicyResp.Response.PolicyId,
-			createUserResp.Response.Uin); err != nil {
-			return nil, err
-		}
+		failListLock.Unlock()
+		return client.AttachUserPolicy(createPolicyResp.Response.PolicyId, createUserResp.Response.Uin)
+	})
+	if err != nil {
+		return nil, err
 	}
 	return inlinePolicies, nil
 }
 
+// forEachPolicy calls fn for each index below n, running at most
+// policyConcurrency calls at a time, and returns the errors of every call.
+func forEachPolicy(n int, fn func(i int) error) error {
+	var (
+		wg      sync.WaitGroup
+		errLock sync.Mutex
+		apiErrs = &multierror.Error{}
+		slots   = make(chan struct{}, policyConcurrency)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fn(i); err != nil {
+				errLock.Lock()
+				apiErrs = multierror.Append(apiErrs, err)
+				errLock.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return apiErrs.ErrorOrNil()
+}
+
+// createPolicyUser creates a CAM user and attaches the role's policies to it,
+// returning the inline policies it created and the remote policies it attached.
+// Every step is pushed to failList so the caller can roll it back.
+func createPolicyUser(userName string, role *roleEntry, failList *list.List, client *clients.CAMClient) (
+	createUserResp *cam.AddUserResponse, inlinePolicies, remotePolicies []*remotePolicy, err error) {
+	// 1>AddUser
+	createUserResp, err = addUserFunc(userName, failList, client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// 2> inlinePolicy
+	inlinePolicies, err = inlinePolicyFunc(createUserResp, role, failList, client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// 3> remotePol
+	remotePolicies = make([]*remotePolicy, len(role.RemotePolicies))
+	var failListLock sync.Mutex
+	err = forEachPolicy(len(role.RemotePolicies), func(i int) error {
+		remotePol := role.RemotePolicies[i]
+		policyId, err := getPolicyIdByRemotePol(remotePol, client)
+		if err != nil {
+			return err
+		}
+		failListLock.Lock()
+		failList.PushBack(&attachUserPolicyFail{policyId, createUserResp.Response.Uin})
+		failListLock.Unlock()
+		if err := client.AttachUserPolicy(policyId, createUserResp.Response.Uin); err != nil {
+			return err
+		}
+		remotePolicies[i] = &remotePolicy{
+			PolicyName: remotePol.PolicyName,
+			Scope:      remotePol.Scope,
+			PolicyId:   *policyId,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return createUserResp, inlinePolicies, remotePolicies, nil
+}
+
 func (b *backend) pathCredsRead(ctx context.Context,
-	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	req *logical.Request, data *framework.FieldData) (resp *logical.Response, err error) {
 	roleName := data.Get("name").(string)
 	externalId := ""
 	if raw, ok := data.GetOk("external_id"); ok {
 		externalId = raw.(string)
 	}
-	role, creds, err := checkData(roleName, ctx, req)
+	role, accountClient, err := b.checkData(roleName, ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := b.requestedTTL(data, role)
 	if err != nil {
 		return nil, err
 	}
+	// 0> count the lease against the limits before anything is created
+	counted, err := b.reserveLease(ctx, req.Storage, roleName, role)
+	if err != nil {
+		if _, ok := err.(leaseLimitError); ok {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+	defer func() {
+		if !counted {
+			return
+		}
+		if err == nil {
+			resp.Secret.InternalData[leaseCountedKey] = true
+			return
+		}
+		if releaseErr := b.releaseLease(ctx, req.Storage, roleName); releaseErr != nil && b.Logger().IsError() {
+			b.Logger().Error(fmt.Sprintf("unable to release lease count of role %s", roleName), "error", releaseErr)
+		}
+	}()
 	switch role.Type() {
 	case roleTypeSTS:
-		return b.roleTypeSTSFunc(creds, req, role, roleName, externalId)
-	case roleTypeCAM:
-		b.profile.HttpProfile.ReqTimeout = 600
-		client, err := clients.NewCAMClient(b.profile, creds.SecretId, creds.SecretKey)
+		externalId, err = resolveExternalId(role, externalId)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = b.roleTypeSTSFunc(accountClient, req, role, roleName, externalId, ttl)
 		if err != nil {
 			return nil, err
 		}
+		if err := b.recordIssued(ctx, req, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	case roleTypeCAM:
+		client := accountClient.cam
 		failList := list.New()
 		success := false
-		// 5> clean up data
+		// 6> clean up data
 		defer func() {
 			// Operation failed, delete data
-			if failList.Len() > 0 && !success {
-				for failList.Len() > 0 {
-					fail := failList.Back()
-					deleteForFail(fail.Value, client, b)
-					failList.Remove(fail)
-				}
+			if !success {
+				rollback(failList, client, b)
 			}
 		}()
-		// 1>AddUser
-		createUserResp, err := addUserFunc(req, roleName, failList, client)
-		if err != nil {
-			return nil, err
-		}
-		// 2> inlinePolicy
-		inlinePolicies, err := inlinePolicyFunc(createUserResp, role, failList, client)
+		// 1-3> take a user from the pool, or create one with the role's policies
+		member, err := b.claimPoolMember(ctx, req.Storage, roleName, role)
 		if err != nil {
 			return nil, err
 		}
-		// 3> remotePol
-		for _, remotePol := range role.RemotePolicies {
-			policyId, err := getPolicyIdByRemotePol(remotePol, client)
+		if member != nil {
+			member.pushFails(failList)
+		} else {
+			userName := generateUsername(req.DisplayName, roleName)
+			createUserResp, inlinePolicies, remotePolicies, err := createPolicyUser(userName, role, failList, client)
 			if err != nil {
 				return nil, err
 			}
-			failList.PushBack(&attachUserPolicyFail{policyId, createUserResp.Response.Uin})
-			if err := client.AttachUserPolicy(policyId, createUserResp.Response.Uin); err != nil {
-				return nil, err
+			member = &poolMember{
+				UserName:       *(createUserResp.Response.Name),
+				Uin:            *(createUserResp.Response.Uin),
+				InlinePolicies: inlinePolicies,
+				RemotePolicies: remotePolicies,
 			}
 		}
 		// 4> CreateAccessKey
-		accessKeyResp, err := client.CreateAccessKey(createUserResp.Response.Uin)
+		accessKeyResp, err := client.CreateAccessKey(&member.Uin)
 		if accessKeyResp != nil && accessKeyResp.Response != nil {
 			failList.PushBack(&createAccessKeyFail{
 				accessKeyResp.Response.AccessKey.AccessKeyId,
-				createUserResp.Response.Uin})
+				&member.Uin})
 		}
 		if err != nil {
 			return nil, err
 		}
-		resp := b.makeResp(accessKeyResp, createUserResp, inlinePolicies, role, roleName)
+		resp := b.makeResp(accessKeyResp, member, role, roleName)
+		// 5> wait for the access key to be accepted
+		if role.WaitForPropagation {
+			if err := b.waitForPropagation(ctx, accountClient.creds,
+				*(accessKeyResp.Response.AccessKey.AccessKeyId),
+				*(accessKeyResp.Response.AccessKey.SecretAccessKey), role.PropagationTimeout); err != nil {
+				// The request is gone, so the credentials could never be returned.
+				if ctx.Err() != nil {
+					return nil, err
+				}
+				resp.AddWarning(err.Error())
+			}
+		}
 		if role.TTL != 0 {
 			resp.Secret.TTL = role.TTL
 		}
+		if ttl != 0 {
+			resp.Secret.TTL = ttl
+			resp.Secret.InternalData["ttl"] = int64(ttl / time.Second)
+		}
 		if role.MaxTTL != 0 {
 			resp.Secret.MaxTTL = role.MaxTTL
 		}
+		if err := b.recordIssued(ctx, req, resp); err != nil {
+			return nil, err
+		}
 		success = true
 		return resp, nil
 	default:
@@ -215,22 +394,69 @@ func (b *backend) pathCredsRead(ctx context.Context,
 	}
 }
 
-func (b *backend) makeResp(accessKeyResp *camLocal.CreateAccessKeyResponse, createUserResp *cam.AddUserResponse,
-	inlinePolicies []*remotePolicy, role *roleEntry, roleName string) *logical.Response {
+// makeResp returns the secret for an access key issued to the user. The remote
+// policies are recorded with the IDs they were attached with.
+func (b *backend) makeResp(accessKeyResp *camLocal.CreateAccessKeyResponse, user *poolMember,
+	role *roleEntry, roleName string) *logical.Response {
 	return b.Secret(secretType).Response(map[string]interface{}{
 		"secret_id":  *(accessKeyResp.Response.AccessKey.AccessKeyId),
 		"secret_key": *(accessKeyResp.Response.AccessKey.SecretAccessKey),
 	}, map[string]interface{}{
 		"role_type":       roleTypeCAM.String(),
 		"role_name":       roleName,
-		"username":        *(createUserResp.Response.Name),
-		"uin":             cast.ToString(*(createUserResp.Response.Uin)),
+		"role_version":    role.Version,
+		"account":         role.Account,
+		"username":        user.UserName,
+		"uin":             cast.ToString(user.Uin),
 		"secret_id":       *(accessKeyResp.Response.AccessKey.AccessKeyId),
-		"inline_policies": inlinePolicies,
-		"remote_policies": role.RemotePolicies,
+		"inline_policies": user.InlinePolicies,
+		"remote_policies": user.RemotePolicies,
 	})
 }
 
+// resolveExternalId returns the external ID to pass to AssumeRole: the one fixed
+// on the role, or the one on the request once it has been checked against the
+// role's constraints.
+func resolveExternalId(role *roleEntry, requested string) (string, error) {
+	if role.ExternalId != "" {
+		if requested != "" {
+			return "", fmt.Errorf("external_id is set by the role and must not be passed")
+		}
+		return role.ExternalId, nil
+	}
+	if requested == "" {
+		if role.ExternalIdRequired {
+			return "", fmt.Errorf("external_id is required for this role")
+		}
+		return "", nil
+	}
+	if len(role.AllowedExternalIds) > 0 && !strutil.StrListContains(role.AllowedExternalIds, requested) {
+		return "", fmt.Errorf("external_id is not allowed for this role")
+	}
+	return requested, nil
+}
+
+// requestedTTL returns the ttl asked for on the request, bounded by the role's
+// max_ttl and the mount's max TTL, or 0 if none was asked for.
+func (b *backend) requestedTTL(data *framework.FieldData, role *roleEntry) (time.Duration, error) {
+	raw, ok := data.GetOk("ttl")
+	if !ok {
+		return 0, nil
+	}
+	ttl := time.Duration(raw.(int)) * time.Second
+	if ttl < 0 {
+		return 0, fmt.Errorf("ttl must not be negative")
+	}
+	_, maxTTL := b.effectiveLeaseTTLs(role)
+	if maxTTL > 0 && ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl, nil
+}
+
+// errNoPolicyData is returned when no policy has the name of a remote policy.
+var errNoPolicyData = errors.New("no data")
+
 func getPolicyIdByRemotePol(remote *remotePolicy, client *clients.CAMClient) (*uint64, error) {
 	req, err := client.ListPolicies(remote.PolicyName, remote.Scope)
 	if err != nil {
@@ -238,18 +464,32 @@ func getPolicyIdByRemotePol(remote *remotePolicy, client *clients.CAMClient) (*u
 	}
 	list := req.Response.List
 	if list == nil || len(list) <= 0 {
-		return nil, fmt.Errorf("policy_name:%s,scope:%s no data", remote.PolicyName, remote.Scope)
+		return nil, fmt.Errorf("policy_name:%s,scope:%s %w", remote.PolicyName, remote.Scope, errNoPolicyData)
 	}
 	policyItem := list[0]
 	return policyItem.PolicyId, nil
 }
 
+func inlinePolicyName(userName string, policy *inlinePolicy) string {
+	return userName + "-" + policy.UUID
+}
+
 func generateUsername(displayName, roleName string) string {
 	return generateName(displayName, roleName, 64)
 }
 
-func generateRoleSessionName(displayName, roleName string) string {
-	return generateName(displayName, roleName, 32)
+// generateRoleSessionName ends in 12 random hex characters rather than the
+// time, so every lease gets a session of its own to revoke.
+func generateRoleSessionName(displayName, roleName string) (string, error) {
+	name := fmt.Sprintf("%s-%s-", displayName, roleName)
+	if len(name) > 32-12 {
+		name = name[:32-12]
+	}
+	random, err := uuid.GenerateRandomBytes(6)
+	if err != nil {
+		return "", err
+	}
+	return name + hex.EncodeToString(random), nil
 }
 
 func generateName(displayName, roleName string, maxLength int) string {
@@ -287,6 +527,15 @@ type createAccessKeyFail struct {
 	Uin         *uint64
 }
 
+// rollback undoes the steps in failList, last first.
+func rollback(failList *list.List, client *clients.CAMClient, b *backend) {
+	for failList.Len() > 0 {
+		fail := failList.Back()
+		deleteForFail(fail.Value, client, b)
+		failList.Remove(fail)
+	}
+}
+
 // Rollback Data
 func deleteForFail(fail interface{}, client *clients.CAMClient, b *backend) {
 	switch fail.(type) {
@@ -1,7 +1,17 @@
 package tencentcloud
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
+	"github.com/hashicorp/vault/sdk/logical"
+	tcerr "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
 )
 
 func TestGenerateUsername(t *testing.T) {
@@ -20,16 +30,163 @@ func TestGenerateUsername(t *testing.T) {
 }
 
 func TestGenerateRoleSessionName(t *testing.T) {
-	result := generateRoleSessionName("displayName", "roleName")
-	if len(result) > 32 {
-		t.Fatalf("too long: %d, %s", len(result), result)
+	for _, names := range [][2]string{
+		{"displayName", "roleName"},
+		{"displayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayName", "roleName"},
+		{"displayName", "roleNameroleNameroleNameroleNameroleNameroleNameroleNameroleNameroleNameroleName"},
+	} {
+		result, err := generateRoleSessionName(names[0], names[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result) > 32 {
+			t.Fatalf("too long: %d, %s", len(result), result)
+		}
 	}
-	result = generateRoleSessionName("displayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayNamedisplayName", "roleName")
-	if len(result) > 32 {
-		t.Fatal("too long: " + result)
+	// Leases issued in the same second still get sessions of their own.
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		result, err := generateRoleSessionName("displayName", "roleName")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[result] {
+			t.Fatalf("generated %s twice", result)
+		}
+		seen[result] = true
 	}
-	result = generateRoleSessionName("displayName", "roleNameroleNameroleNameroleNameroleNameroleNameroleNameroleNameroleNameroleName")
-	if len(result) > 32 {
-		t.Fatal("too long: " + result)
+}
+
+func TestResolveExternalId(t *testing.T) {
+	fixed := &roleEntry{ExternalId: "fixed"}
+	if externalId, err := resolveExternalId(fixed, ""); err != nil || externalId != "fixed" {
+		t.Fatalf("expected fixed external id but received %q, %v", externalId, err)
+	}
+	if _, err := resolveExternalId(fixed, "other"); err == nil {
+		t.Fatal("expected an error passing an external id to a role that fixes it")
+	}
+
+	required := &roleEntry{ExternalIdRequired: true, AllowedExternalIds: []string{"a", "b"}}
+	if _, err := resolveExternalId(required, ""); err == nil {
+		t.Fatal("expected an error omitting a required external id")
+	}
+	if _, err := resolveExternalId(required, "c"); err == nil {
+		t.Fatal("expected an error passing an external id that is not allowed")
+	}
+	if externalId, err := resolveExternalId(required, "b"); err != nil || externalId != "b" {
+		t.Fatalf("expected external id b but received %q, %v", externalId, err)
+	}
+
+	open := &roleEntry{}
+	if externalId, err := resolveExternalId(open, ""); err != nil || externalId != "" {
+		t.Fatalf("expected no external id but received %q, %v", externalId, err)
+	}
+}
+
+func TestForEachPolicy(t *testing.T) {
+	var running, maxRunning int32
+	calls := make([]bool, 10)
+	err := forEachPolicy(len(calls), func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		calls[i] = true
+		if i%3 == 0 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	for i, called := range calls {
+		if !called {
+			t.Fatalf("expected policy %d to be handled", i)
+		}
+	}
+	if maxRunning > policyConcurrency {
+		t.Fatalf("expected at most %d concurrent calls but received %d", policyConcurrency, maxRunning)
+	}
+	if err == nil {
+		t.Fatal("expected the failed calls to be returned")
+	}
+	if merr, ok := err.(interface{ WrappedErrors() []error }); !ok || len(merr.WrappedErrors()) != 4 {
+		t.Fatalf("expected 4 errors but received %v", err)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	for code, notFound := range map[string]bool{
+		"InvalidParameter.UserNotExist":     true,
+		"InvalidParameter.PolicyIdNotExist": true,
+		"ResourceNotFound.PolicyIdNotFound": true,
+		"InvalidParameter.GroupNotExist":    true,
+		"ResourceNotFound.GroupNotExist":    true,
+		"FailedOperation.Accesskey":         true,
+		"AuthFailure.SecretIdNotFound":      false,
+		"InternalError.SystemError":         false,
+	} {
+		err := fmt.Errorf("wrapped: %w", tcerr.NewTencentCloudSDKError(code, "", ""))
+		if clients.IsNotFound(err) != notFound {
+			t.Fatalf("expected IsNotFound to be %t for %s", notFound, code)
+		}
+		if (clients.IgnoreNotFound(err) == nil) != notFound {
+			t.Fatalf("expected IgnoreNotFound to ignore only not found errors, received %v for %s", clients.IgnoreNotFound(err), code)
+		}
+	}
+	if clients.IsNotFound(errors.New("InvalidParameter.UserNotExist")) {
+		t.Fatal("expected errors not from Tencent Cloud to never be not found")
+	}
+}
+
+func TestRevokedSessionsPolicyName(t *testing.T) {
+	ctx := context.Background()
+	s := &logical.InmemStorage{}
+	name, err := revokedSessionsPolicyName(ctx, s, "firingrole001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(name, revokedSessionsPolicyPrefix+"firingrole001-") {
+		t.Fatalf("received unexpected policy name %s", name)
+	}
+	if again, err := revokedSessionsPolicyName(ctx, s, "firingrole001"); err != nil || again != name {
+		t.Fatalf("expected the policy name %s to stay the same but received %s, %v", name, again, err)
+	}
+	other, err := revokedSessionsPolicyName(ctx, &logical.InmemStorage{}, "firingrole001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == name {
+		t.Fatalf("expected another mount to use another policy name than %s", name)
+	}
+}
+
+func TestRevokedSessionsPolicyDocument(t *testing.T) {
+	revocation := &stsRevocation{
+		RoleARN:  "qcs::cam::uin/100021543443:roleName/firingrole001",
+		Sessions: map[string]time.Time{},
+	}
+	for i := 0; len(revocation.Sessions) < 10; i++ {
+		roleSessionName, err := generateRoleSessionName("displayName", fmt.Sprintf("role%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		revocation.Sessions[roleSessionName] = time.Now()
+	}
+	if _, err := revokedSessionsPolicyDocument(revocation); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; len(revocation.Sessions) < 1000; i++ {
+		roleSessionName, err := generateRoleSessionName("displayName", fmt.Sprintf("role%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		revocation.Sessions[roleSessionName] = time.Now()
+	}
+	if _, err := revokedSessionsPolicyDocument(revocation); err == nil {
+		t.Fatal("expected too many revoked sessions to fail")
 	}
 }
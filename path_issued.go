@@ -0,0 +1,276 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/spf13/cast"
+)
+
+const issuedPath = "issued/"
+
+// issuedCredential indexes an issued secret by its secret ID, so a leaked key
+// can be traced back to the role and identity it was issued to.
+type issuedCredential struct {
+	SecretId        string          `json:"secret_id"`
+	RoleName        string          `json:"role_name"`
+	RoleType        string          `json:"role_type"`
+	Account         string          `json:"account"`
+	UserName        string          `json:"username,omitempty"`
+	Uin             string          `json:"uin,omitempty"`
+	PolicyIds       []uint64        `json:"policy_ids,omitempty"`
+	InlinePolicies  []*remotePolicy `json:"inline_policies,omitempty"`
+	RemotePolicies  []*remotePolicy `json:"remote_policies,omitempty"`
+	RoleARN         string          `json:"role_arn,omitempty"`
+	SourceRoleARNs  []string        `json:"source_role_arn,omitempty"`
+	RoleSessionName string          `json:"role_session_name,omitempty"`
+	RevokeSessions  bool            `json:"revoke_sessions,omitempty"`
+	IssueTime       time.Time       `json:"issue_time"`
+	Expiration      time.Time       `json:"expiration"`
+	EntityID        string          `json:"entity_id"`
+	DisplayName     string          `json:"display_name"`
+	LeasePath       string          `json:"lease_path"`
+	// RevokedAt is set once the credential is revoked through lookup, ahead
+	// of its lease.
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+func (c *issuedCredential) responseData() map[string]interface{} {
+	return map[string]interface{}{
+		"secret_id":         c.SecretId,
+		"role_name":         c.RoleName,
+		"role_type":         c.RoleType,
+		"account":           c.Account,
+		"username":          c.UserName,
+		"uin":               c.Uin,
+		"policy_ids":        c.PolicyIds,
+		"role_arn":          c.RoleARN,
+		"role_session_name": c.RoleSessionName,
+		"issue_time":        c.IssueTime,
+		"expiration":        c.Expiration,
+		"entity_id":         c.EntityID,
+		"display_name":      c.DisplayName,
+		"lease_path":        c.LeasePath,
+		"revoked_at":        c.RevokedAt,
+	}
+}
+
+func pathListIssued(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: issuedPath + "?$",
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Only list credentials issued for this role.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathIssuedList,
+			},
+		},
+		HelpSynopsis:    pathListIssuedHelpSyn,
+		HelpDescription: pathListIssuedHelpDesc,
+	}
+}
+
+func pathIssued(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: issuedPath + "(?P<secret_id>.+)",
+		Fields: map[string]*framework.FieldSchema{
+			"secret_id": {
+				Type:        framework.TypeString,
+				Description: "The secret ID of the issued credential.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathIssuedRead,
+			},
+		},
+		HelpSynopsis:    pathIssuedHelpSyn,
+		HelpDescription: pathIssuedHelpDesc,
+	}
+}
+
+func (b *backend) pathIssuedList(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role").(string)
+	secretIds, err := req.Storage.List(ctx, issuedPath)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(secretIds))
+	keyInfo := make(map[string]interface{}, len(secretIds))
+	for _, secretId := range secretIds {
+		issued, err := readIssued(ctx, req.Storage, secretId)
+		if err != nil {
+			return nil, err
+		}
+		if issued == nil || (roleName != "" && issued.RoleName != roleName) {
+			continue
+		}
+		keys = append(keys, secretId)
+		keyInfo[secretId] = map[string]interface{}{
+			"role_name":  issued.RoleName,
+			"role_type":  issued.RoleType,
+			"username":   issued.UserName,
+			"issue_time": issued.IssueTime,
+			"expiration": issued.Expiration,
+			"entity_id":  issued.EntityID,
+		}
+	}
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *backend) pathIssuedRead(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issued, err := readIssued(ctx, req.Storage, data.Get("secret_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issued == nil {
+		return nil, nil
+	}
+	return &logical.Response{
+		Data: issued.responseData(),
+	}, nil
+}
+
+// recordIssued indexes the secret of a response under its secret ID.
+func (b *backend) recordIssued(ctx context.Context, req *logical.Request, resp *logical.Response) error {
+	return b.indexIssued(ctx, req.Storage, resp, req.EntityID, req.DisplayName, req.MountPoint+req.Path)
+}
+
+// recordRenewedIssued indexes the new secret of a renewed lease. Renew requests
+// don't carry who asked for the lease, so that is copied from the entry of the
+// secret it replaces.
+func (b *backend) recordRenewedIssued(ctx context.Context, req *logical.Request, resp *logical.Response,
+	previousSecretId string) error {
+	previous, err := readIssued(ctx, req.Storage, previousSecretId)
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		previous = &issuedCredential{}
+	}
+	return b.indexIssued(ctx, req.Storage, resp, previous.EntityID, previous.DisplayName, previous.LeasePath)
+}
+
+func (b *backend) indexIssued(ctx context.Context, s logical.Storage, resp *logical.Response,
+	entityID, displayName, leasePath string) error {
+	internalData := resp.Secret.InternalData
+	now := time.Now().UTC()
+	ttl := resp.Secret.TTL
+	if ttl == 0 {
+		ttl = b.System().DefaultLeaseTTL()
+	}
+	issued := &issuedCredential{
+		SecretId:        cast.ToString(resp.Data["secret_id"]),
+		RoleName:        cast.ToString(internalData["role_name"]),
+		RoleType:        cast.ToString(internalData["role_type"]),
+		Account:         cast.ToString(internalData["account"]),
+		UserName:        cast.ToString(internalData["username"]),
+		Uin:             cast.ToString(internalData["uin"]),
+		RoleARN:         cast.ToString(internalData["role_arn"]),
+		SourceRoleARNs:  cast.ToStringSlice(internalData["source_role_arn"]),
+		RoleSessionName: cast.ToString(internalData["role_session_name"]),
+		RevokeSessions:  cast.ToBool(internalData["revoke_sessions"]),
+		IssueTime:       now,
+		Expiration:      now.Add(ttl),
+		EntityID:        entityID,
+		DisplayName:     displayName,
+		LeasePath:       leasePath,
+	}
+	if issued.RoleType == roleTypeCAM.String() {
+		var err error
+		if issued.InlinePolicies, err = getRemotePolicies(internalData, "inline_policies"); err != nil {
+			return err
+		}
+		if issued.RemotePolicies, err = getRemotePolicies(internalData, "remote_policies"); err != nil {
+			return err
+		}
+		for _, policy := range append(issued.InlinePolicies, issued.RemotePolicies...) {
+			issued.PolicyIds = append(issued.PolicyIds, policy.PolicyId)
+		}
+	}
+	return writeIssued(ctx, s, issued)
+}
+
+// extendIssued moves the expiration of a renewed secret.
+func (b *backend) extendIssued(ctx context.Context, s logical.Storage, secretId string, ttl time.Duration) error {
+	issued, err := readIssued(ctx, s, secretId)
+	if err != nil || issued == nil {
+		return err
+	}
+	issued.Expiration = time.Now().UTC().Add(ttl)
+	return writeIssued(ctx, s, issued)
+}
+
+// tidyIssued drops the entries of STS credentials that have expired. Their
+// leases may have been renewed with new credentials, and the old ones are
+// kept indexed only until they stop working.
+func (b *backend) tidyIssued(ctx context.Context, s logical.Storage) error {
+	secretIds, err := s.List(ctx, issuedPath)
+	if err != nil {
+		return err
+	}
+	for _, secretId := range secretIds {
+		issued, err := readIssued(ctx, s, secretId)
+		if err != nil {
+			return err
+		}
+		if issued == nil || issued.RoleType != roleTypeSTS.String() || time.Now().Before(issued.Expiration) {
+			continue
+		}
+		if err := s.Delete(ctx, issuedPath+secretId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readIssued(ctx context.Context, s logical.Storage, secretId string) (*issuedCredential, error) {
+	entry, err := s.Get(ctx, issuedPath+secretId)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	issued := &issuedCredential{}
+	if err := entry.DecodeJSON(issued); err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+func writeIssued(ctx context.Context, s logical.Storage, issued *issuedCredential) error {
+	if issued.SecretId == "" {
+		return fmt.Errorf("unable to index a credential without a secret_id")
+	}
+	entry, err := logical.StorageEntryJSON(issuedPath+issued.SecretId, issued)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+const pathListIssuedHelpSyn = "List the credentials issued and not yet revoked."
+
+const pathListIssuedHelpDesc = `
+Lists the secret IDs of the credentials issued by this backend whose leases
+have not been revoked, along with their role, user and issue and expiration
+times. The role parameter limits the list to one role. STS credentials replaced
+by a renewal stay listed until they expire.
+`
+
+const pathIssuedHelpSyn = "Look up an issued credential by its secret ID."
+
+const pathIssuedHelpDesc = `
+Returns the role, CAM user, UIN, policy IDs, issue and expiration times and the
+identity of the requester of the credential with the given secret ID.
+`
@@ -0,0 +1,168 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/spf13/cast"
+)
+
+func pathLookup(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "lookup$",
+		Fields: map[string]*framework.FieldSchema{
+			"access_key_id": {
+				Type:        framework.TypeString,
+				Description: "The access key ID, or STS TmpSecretId, of the credential.",
+			},
+			"username": {
+				Type:        framework.TypeString,
+				Description: "The name of the CAM user the credential was issued to.",
+			},
+			"uin": {
+				Type:        framework.TypeString,
+				Description: "The UIN of the CAM user the credential was issued to.",
+			},
+			"revoke": {
+				Type: framework.TypeBool,
+				Description: `If true, the matching credentials are revoked in Tencent Cloud right away.
+Their leases are left for Vault to expire or revoke.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathLookup,
+			},
+		},
+		HelpSynopsis:    pathLookupHelpSyn,
+		HelpDescription: pathLookupHelpDesc,
+	}
+}
+
+func (b *backend) pathLookup(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	accessKeyId := data.Get("access_key_id").(string)
+	userName := data.Get("username").(string)
+	uin := data.Get("uin").(string)
+	given := 0
+	for _, value := range []string{accessKeyId, userName, uin} {
+		if value != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		return nil, fmt.Errorf("exactly one of access_key_id, username or uin is required")
+	}
+
+	var matches []*issuedCredential
+	if accessKeyId != "" {
+		issued, err := readIssued(ctx, req.Storage, accessKeyId)
+		if err != nil {
+			return nil, err
+		}
+		if issued != nil {
+			matches = append(matches, issued)
+		}
+	} else {
+		secretIds, err := req.Storage.List(ctx, issuedPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, secretId := range secretIds {
+			issued, err := readIssued(ctx, req.Storage, secretId)
+			if err != nil {
+				return nil, err
+			}
+			if issued == nil || issued.RoleType != roleTypeCAM.String() {
+				continue
+			}
+			if (userName != "" && issued.UserName == userName) || (uin != "" && issued.Uin == uin) {
+				matches = append(matches, issued)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	if data.Get("revoke").(bool) {
+		apiErrs := &multierror.Error{}
+		for _, issued := range matches {
+			if err := b.revokeIssued(ctx, req.Storage, issued); err != nil {
+				apiErrs = multierror.Append(apiErrs, fmt.Errorf("unable to revoke %s: %w", issued.SecretId, err))
+			}
+		}
+		if err := apiErrs.ErrorOrNil(); err != nil {
+			return nil, err
+		}
+	}
+	credentials := make([]map[string]interface{}, len(matches))
+	for i, issued := range matches {
+		credentials[i] = issued.responseData()
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"credentials": credentials,
+		},
+	}, nil
+}
+
+// revokeIssued revokes a credential in Tencent Cloud ahead of its lease, and
+// marks it as revoked so revoking the lease later has nothing left to do.
+// Temporary credentials can't be deleted, so their session is denied on the
+// role they were assumed from instead.
+func (b *backend) revokeIssued(ctx context.Context, s logical.Storage, issued *issuedCredential) error {
+	if !issued.RevokedAt.IsZero() {
+		return nil
+	}
+	switch issued.RoleType {
+	case roleTypeCAM.String():
+		client, err := b.accountCAMClient(ctx, s, issued.Account)
+		if err != nil {
+			return err
+		}
+		if err := revokeCAMCredential(client, issued.SecretId, issued.UserName, uint64(cast.ToInt64(issued.Uin)),
+			issued.InlinePolicies, issued.RemotePolicies); err != nil {
+			return err
+		}
+	case roleTypeSTS.String():
+		// The deny policy is only managed for roles that opted in, with the
+		// credentials that can reach the role.
+		if len(issued.SourceRoleARNs) > 0 {
+			return fmt.Errorf("STS credentials of role %s can't be revoked, they were assumed "+
+				"through source_role_arn and the configured credentials can't manage %s", issued.RoleName, issued.RoleARN)
+		}
+		if !issued.RevokeSessions {
+			return fmt.Errorf("STS credentials of role %s can't be revoked, sts_revoke_sessions was not set "+
+				"when they were issued so they remain valid until they expire", issued.RoleName)
+		}
+		if err := b.revokeSTSSession(ctx, s, issued.Account, issued.RoleARN, issued.RoleSessionName); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unrecognized role_type: %s", issued.RoleType)
+	}
+	issued.RevokedAt = time.Now().UTC()
+	return writeIssued(ctx, s, issued)
+}
+
+const pathLookupHelpSyn = `
+Find the role, lease and requester of an issued credential, and optionally revoke it.
+`
+
+const pathLookupHelpDesc = `
+Looks up issued credentials by access key ID, as found in CloudAudit logs, or
+by CAM username or UIN, and returns what "issued/<secret_id>" returns for each:
+the role, the path their lease was created under, issue and expiration times
+and the entity and display name of the requester.
+
+With revoke set, CAM credentials have their access key, policies and user
+deleted, and STS credentials have their session denied on the role they were
+assumed from. STS credentials can only be revoked if their role had
+sts_revoke_sessions set when they were issued, and not source_role_arn. The Vault lease is not revoked; once it expires or is revoked
+nothing further is deleted.
+`
@@ -0,0 +1,191 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// defaultSTSDuration is the lifetime Tencent Cloud gives AssumeRole credentials
+// when no DurationSeconds is sent.
+const defaultSTSDuration = 7200 * time.Second
+
+func pathRolePreview(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name") + "/preview$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "The name of the role.",
+			},
+			"external_id": {
+				Type:        framework.TypeString,
+				Description: "The external ID that would be passed to AssumeRole for STS roles.",
+			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "The ttl that would be requested when reading credentials.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathRolePreviewRead,
+			},
+		},
+		HelpSynopsis:    pathRolePreviewHelpSyn,
+		HelpDescription: pathRolePreviewHelpDesc,
+	}
+}
+
+func (b *backend) pathRolePreviewRead(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	externalId := ""
+	if raw, ok := data.GetOk("external_id"); ok {
+		externalId = raw.(string)
+	}
+	role, accountClient, err := b.checkData(roleName, ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	requestedTTL, err := b.requestedTTL(data, role)
+	if err != nil {
+		return nil, err
+	}
+	switch role.Type() {
+	case roleTypeSTS:
+		resolvedExternalId, err := resolveExternalId(role, externalId)
+		if err != nil {
+			return nil, err
+		}
+		externalIdSource := "none"
+		switch {
+		case role.ExternalId != "":
+			// The role's external ID is never returned, only that it will be used.
+			externalIdSource = "role"
+			resolvedExternalId = ""
+		case resolvedExternalId != "":
+			externalIdSource = "request"
+		}
+		// Vault caps the lease of the default duration like a requested ttl.
+		duration := defaultSTSDuration
+		if _, leaseMaxTTL := b.effectiveLeaseTTLs(role); leaseMaxTTL > 0 && duration > leaseMaxTTL {
+			duration = leaseMaxTTL
+		}
+		if requestedTTL != 0 {
+			duration = requestedTTL
+		}
+		maxTTL := duration
+		if role.STSRenewable {
+			_, maxTTL = b.effectiveLeaseTTLs(role)
+		}
+		roleSessionName, err := generateRoleSessionName(req.DisplayName, roleName)
+		if err != nil {
+			return nil, err
+		}
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"role_type":          roleTypeSTS.String(),
+				"account":            role.Account,
+				"role_arn":           role.RoleARN,
+				"source_role_arn":    role.SourceRoleARNs,
+				"role_session_name":  roleSessionName,
+				"external_id":        resolvedExternalId,
+				"external_id_source": externalIdSource,
+				"ttl":                int64(duration / time.Second),
+				"max_ttl":            int64(maxTTL / time.Second),
+				"renewable":          role.STSRenewable,
+			},
+		}, nil
+	case roleTypeCAM:
+		client := accountClient.cam
+		userName := generateUsername(req.DisplayName, roleName)
+		inlinePolicies := make([]map[string]interface{}, len(role.InlinePolicies))
+		for i, inlinePolicy := range role.InlinePolicies {
+			policyDoc, err := jsonutil.EncodeJSON(inlinePolicy.PolicyDocument)
+			if err != nil {
+				return nil, err
+			}
+			inlinePolicies[i] = map[string]interface{}{
+				"policy_name":     inlinePolicyName(userName, inlinePolicy),
+				"policy_document": string(policyDoc),
+			}
+		}
+		remotePolicies := make([]*remotePolicy, len(role.RemotePolicies))
+		for i, remotePol := range role.RemotePolicies {
+			policyId, err := getPolicyIdByRemotePol(remotePol, client)
+			if err != nil {
+				return nil, err
+			}
+			remotePolicies[i] = &remotePolicy{
+				PolicyName: remotePol.PolicyName,
+				Scope:      remotePol.Scope,
+				PolicyId:   *policyId,
+			}
+		}
+		ttl, maxTTL := b.effectiveLeaseTTLs(role)
+		if requestedTTL != 0 {
+			ttl = requestedTTL
+		}
+		userNameSource := "generated"
+		if role.PoolSize > 0 {
+			userNameSource = "pool"
+		}
+		resp := &logical.Response{
+			Data: map[string]interface{}{
+				"role_type":       roleTypeCAM.String(),
+				"account":         role.Account,
+				"username":        userName,
+				"username_source": userNameSource,
+				"inline_policies": inlinePolicies,
+				"remote_policies": remotePolicies,
+				"ttl":             int64(ttl / time.Second),
+				"max_ttl":         int64(maxTTL / time.Second),
+				"renewable":       true,
+			},
+		}
+		if role.PoolSize > 0 {
+			resp.AddWarning(fmt.Sprintf("role has a pool_size of %d: the user is taken from the pool and "+
+				"this username won't be generated, unless the pool is empty", role.PoolSize))
+		}
+		return resp, nil
+	default:
+		return nil, fmt.Errorf("unsupported role type: %s", role.Type())
+	}
+}
+
+// effectiveLeaseTTLs mirrors how Vault core settles the lease of a CAM secret:
+// role values fall back to the mount defaults and are capped by the mount max.
+func (b *backend) effectiveLeaseTTLs(role *roleEntry) (ttl, maxTTL time.Duration) {
+	mountMaxTTL := b.System().MaxLeaseTTL()
+	ttl = role.TTL
+	if ttl == 0 {
+		ttl = b.System().DefaultLeaseTTL()
+	}
+	maxTTL = role.MaxTTL
+	if maxTTL == 0 || (mountMaxTTL > 0 && maxTTL > mountMaxTTL) {
+		maxTTL = mountMaxTTL
+	}
+	if maxTTL > 0 && ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl, maxTTL
+}
+
+const pathRolePreviewHelpSyn = `
+Show what reading credentials for a role would create, without creating anything.
+`
+
+const pathRolePreviewHelpDesc = `
+This path resolves everything a read of "creds/<name>" would do: the generated
+user or session name, the rendered inline policy documents, the IDs of the
+remote policies, the STS parameters and the effective TTL and max TTL once the
+mount limits are applied. Nothing is created in Tencent Cloud. The timestamp
+and random suffix of generated names will differ on the actual read. Roles
+with a pool hand out a pooled user instead, unless the pool is empty, which
+username_source reports.
+`
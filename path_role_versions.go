@@ -0,0 +1,202 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	roleVersionPath = "role_version/"
+
+	// roleVersionsKept is the number of versions retained for each role.
+	roleVersionsKept = 10
+)
+
+type roleVersion struct {
+	Version     int        `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+	EntityID    string     `json:"entity_id"`
+	DisplayName string     `json:"display_name"`
+	Role        *roleEntry `json:"role"`
+}
+
+func pathListRoleVersions(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name") + "/versions/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "The name of the role.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathRoleVersionsList,
+			},
+		},
+		HelpSynopsis:    pathListRoleVersionsHelpSyn,
+		HelpDescription: pathListRoleVersionsHelpDesc,
+	}
+}
+
+func pathRoleVersion(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name") + "/versions/(?P<version>\\d+)$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "The name of the role.",
+			},
+			"version": {
+				Type:        framework.TypeInt,
+				Description: "The version of the role.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathRoleVersionRead,
+			},
+		},
+		HelpSynopsis:    pathRoleVersionHelpSyn,
+		HelpDescription: pathRoleVersionHelpDesc,
+	}
+}
+
+func (b *backend) pathRoleVersionsList(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	versions, err := listRoleVersions(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(versions))
+	keyInfo := make(map[string]interface{}, len(versions))
+	for _, version := range versions {
+		entry, err := readRoleVersion(ctx, req.Storage, roleName, version)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		key := strconv.Itoa(version)
+		keys = append(keys, key)
+		keyInfo[key] = map[string]interface{}{
+			"created_at":   entry.CreatedAt,
+			"entity_id":    entry.EntityID,
+			"display_name": entry.DisplayName,
+		}
+	}
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *backend) pathRoleVersionRead(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	entry, err := readRoleVersion(ctx, req.Storage, roleName, data.Get("version").(int))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	respData := roleResponseData(entry.Role)
+	respData["created_at"] = entry.CreatedAt
+	respData["entity_id"] = entry.EntityID
+	respData["display_name"] = entry.DisplayName
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+// listRoleVersions returns the stored version numbers of a role in ascending order.
+func listRoleVersions(ctx context.Context, s logical.Storage, roleName string) ([]int, error) {
+	keys, err := s.List(ctx, roleVersionPath+roleName+"/")
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(keys))
+	for _, key := range keys {
+		version, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+func latestRoleVersion(ctx context.Context, s logical.Storage, roleName string) (int, error) {
+	versions, err := listRoleVersions(ctx, s, roleName)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, nil
+	}
+	return versions[len(versions)-1], nil
+}
+
+func saveRoleVersion(ctx context.Context, s logical.Storage, roleName string, version *roleVersion) error {
+	entry, err := logical.StorageEntryJSON(roleVersionStoragePath(roleName, version.Version), version)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return err
+	}
+	versions, err := listRoleVersions(ctx, s, roleName)
+	if err != nil {
+		return err
+	}
+	for len(versions) > roleVersionsKept {
+		if err := s.Delete(ctx, roleVersionStoragePath(roleName, versions[0])); err != nil {
+			return err
+		}
+		versions = versions[1:]
+	}
+	return nil
+}
+
+func readRoleVersion(ctx context.Context, s logical.Storage, roleName string, version int) (*roleVersion, error) {
+	entry, err := s.Get(ctx, roleVersionStoragePath(roleName, version))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	result := &roleVersion{}
+	if err := entry.DecodeJSON(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func roleVersionStoragePath(roleName string, version int) string {
+	return fmt.Sprintf("%s%s/%d", roleVersionPath, roleName, version)
+}
+
+const pathListRoleVersionsHelpSyn = "List the stored versions of a role."
+
+const pathListRoleVersionsHelpDesc = `
+Versions are listed by number along with the time they were written and the
+entity that wrote them. The last 10 versions of each role are kept, and they
+remain readable after the role is deleted so that leases issued from it can
+still be audited.
+`
+
+const pathRoleVersionHelpSyn = "Read a stored version of a role."
+
+const pathRoleVersionHelpDesc = `
+Returns the role as it was when the given version was written, along with the
+time and the entity that wrote it. The version a lease was issued from is kept
+in the lease's internal data as "role_version".
+`
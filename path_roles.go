@@ -3,13 +3,17 @@ package tencentcloud
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/spf13/cast"
 )
 
 const (
@@ -35,11 +39,26 @@ func (t roleType) String() string {
 }
 
 type roleEntry struct {
-	RoleARN        string          `json:"role_arn"`
-	RemotePolicies []*remotePolicy `json:"remote_policies"`
-	InlinePolicies []*inlinePolicy `json:"inline_policies"`
-	TTL            time.Duration   `json:"ttl"`
-	MaxTTL         time.Duration   `json:"max_ttl"`
+	RoleARN             string            `json:"role_arn"`
+	SourceRoleARNs      []string          `json:"source_role_arn"`
+	RemotePolicies      []*remotePolicy   `json:"remote_policies"`
+	InlinePolicies      []*inlinePolicy   `json:"inline_policies"`
+	TTL                 time.Duration     `json:"ttl"`
+	MaxTTL              time.Duration     `json:"max_ttl"`
+	ExternalId          string            `json:"external_id"`
+	AllowedExternalIds  []string          `json:"allowed_external_ids"`
+	ExternalIdRequired  bool              `json:"external_id_required"`
+	STSRenewable        bool              `json:"sts_renewable"`
+	STSRevokeSessions   bool              `json:"sts_revoke_sessions"`
+	WaitForPropagation  bool              `json:"wait_for_propagation"`
+	PropagationTimeout  time.Duration     `json:"propagation_timeout"`
+	PoolSize            int               `json:"pool_size"`
+	MaxConcurrentLeases int               `json:"max_concurrent_leases"`
+	IssueRateLimit      int               `json:"issue_rate_limit"`
+	Account             string            `json:"account"`
+	Tags                map[string]string `json:"tags"`
+	Version             int               `json:"version"`
+	LastModified        time.Time         `json:"last_modified"`
 }
 
 type inlinePolicy struct {
@@ -53,6 +72,14 @@ type remotePolicy struct {
 	PolicyId   uint64 `json:"policy_id"`
 }
 
+// leaseLimits returns the limits on the leases of the role.
+func (r *roleEntry) leaseLimits() leaseLimits {
+	return leaseLimits{
+		MaxConcurrentLeases: r.MaxConcurrentLeases,
+		IssueRateLimit:      r.IssueRateLimit,
+	}
+}
+
 // Type
 func (r *roleEntry) Type() roleType {
 	if r.RoleARN != "" {
@@ -75,6 +102,28 @@ func parseRoleType(nameOfRoleType string) (roleType, error) {
 func pathListRoles(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "role/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"detailed": {
+				Type:        framework.TypeBool,
+				Description: "If true, return the details of each role along with its name.",
+			},
+			"type": {
+				Type:        framework.TypeString,
+				Description: `Only list roles of this type, "cam" or "sts".`,
+			},
+			"tag": {
+				Type:        framework.TypeKVPairs,
+				Description: `Only list roles carrying all of these tags, given as "key=value" pairs.`,
+			},
+			"after": {
+				Type:        framework.TypeString,
+				Description: "Only list roles whose name sorts after this value.",
+			},
+			"limit": {
+				Type:        framework.TypeInt,
+				Description: "The maximum number of roles to list. Defaults to all roles.",
+			},
+		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ListOperation: &framework.PathOperation{
 				Callback: b.pathRolesList,
@@ -101,13 +150,14 @@ and the secret id and key that will be used to assume the role (in /config) must
 as a trusted actor`,
 			},
 			"inline_policies": {
-				Type:        framework.TypeString,
-				Description: "JSON of policies to be dynamically applied to users of this role.",
+				Type: framework.TypeSlice,
+				Description: `Policies to be dynamically applied to users of this role. Either a list of
+policy documents, or a JSON string holding that list.`,
 			},
 			"remote_policies": {
-				Type: framework.TypeStringSlice,
-				Description: `The name and type of each remote policy to be applied.
-Example: "policy_name:QcloudAFCFullAccess,scope:All".`,
+				Type: framework.TypeSlice,
+				Description: `The name and scope of each remote policy to be applied. Either a list of
+objects with "policy_name" and "scope", or strings like "policy_name:QcloudAFCFullAccess,scope:All".`,
 			},
 			"ttl": {
 				Type: framework.TypeDurationSecond,
@@ -118,6 +168,70 @@ to 0, in which case the value will fallback to the system/mount defaults.`,
 				Type:        framework.TypeDurationSecond,
 				Description: "The maximum allowed lifetime of tokens issued using this role.",
 			},
+			"external_id": {
+				Type: framework.TypeString,
+				Description: `External ID passed to AssumeRole for every request on this role. Callers
+don't need to know it, and it is never returned on read.`,
+			},
+			"allowed_external_ids": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `External IDs callers may pass when reading creds. If empty, any external ID
+is accepted. Ignored when external_id is set.`,
+			},
+			"source_role_arn": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `ARN of a hub role to assume with the configured credentials before assuming
+role_arn with its temporary credentials. A list of ARNs is assumed in order, each with the
+credentials of the previous one.`,
+			},
+			"external_id_required": {
+				Type:        framework.TypeBool,
+				Description: "If true, callers must pass an external ID when reading creds.",
+			},
+			"sts_renewable": {
+				Type: framework.TypeBool,
+				Description: `If true, renewing an STS lease assumes role_arn again and returns fresh
+temporary credentials, until the lease reaches max_ttl.`,
+			},
+			"sts_revoke_sessions": {
+				Type: framework.TypeBool,
+				Description: `If true, revoking an STS lease attaches a deny policy for its session to
+role_arn, so the temporary credentials stop working before they expire. The credentials
+in /config must be allowed to manage policies on that role.`,
+			},
+			"wait_for_propagation": {
+				Type: framework.TypeBool,
+				Description: `If true, reading creds only returns once the new access key is accepted
+by Tencent Cloud, polling STS GetCallerIdentity with it. Only applies to roles without role_arn.`,
+			},
+			"propagation_timeout": {
+				Type: framework.TypeDurationSecond,
+				Description: `How long to wait for a new access key to be accepted when
+wait_for_propagation is set. Defaults to 60 seconds.`,
+			},
+			"pool_size": {
+				Type: framework.TypeInt,
+				Description: fmt.Sprintf(`Number of CAM users to keep created ahead of time with the
+role's policies attached, so reading creds only has to issue an access key. Only applies to
+roles without role_arn. At most %d.`, maxPoolSize),
+			},
+			"max_concurrent_leases": {
+				Type:        framework.TypeInt,
+				Description: "The most unrevoked leases the role may have at once. 0 means no limit.",
+			},
+			"issue_rate_limit": {
+				Type:        framework.TypeInt,
+				Description: "The most credentials the role may issue per minute. 0 means no limit.",
+			},
+			"account": {
+				Type: framework.TypeLowerCaseString,
+				Description: `Name of the account under config/account/ whose credentials are used for
+this role. Defaults to the credentials in config.`,
+			},
+			"tags": {
+				Type:        framework.TypeKVPairs,
+				Description: `Arbitrary "key=value" tags used to organize and filter roles.`,
+			},
 		},
 		ExistenceCheck: b.pathRoleExistenceCheck,
 		Operations: map[logical.Operation]framework.OperationHandler{
@@ -148,13 +262,25 @@ func (b *backend) pathRoleExistenceCheck(ctx context.Context,
 	return entry != nil, nil
 }
 
-func roleInlinePolicies(policyDocsStr string, role *roleEntry) (err error) {
+func roleInlinePolicies(rawPolicies []interface{}, role *roleEntry) (err error) {
 	var policyDocs []map[string]interface{}
-	if err = json.Unmarshal([]byte(policyDocsStr), &policyDocs); err != nil {
-		return err
+	for _, rawPolicy := range rawPolicies {
+		docs, err := parseInlinePolicies(rawPolicy)
+		if err != nil {
+			return err
+		}
+		policyDocs = append(policyDocs, docs...)
 	}
+	existing := role.InlinePolicies
 	role.InlinePolicies = make([]*inlinePolicy, len(policyDocs))
 	for i, policyDoc := range policyDocs {
+		// Keep the UUID of documents that did not change so the policy names
+		// stay comparable across role versions.
+		if prev := findInlinePolicy(existing, policyDoc); prev != nil {
+			role.InlinePolicies[i] = prev
+			existing = removeInlinePolicy(existing, prev)
+			continue
+		}
 		uid, err := uuid.GenerateUUID()
 		if err != nil {
 			return err
@@ -168,42 +294,138 @@ func roleInlinePolicies(policyDocsStr string, role *roleEntry) (err error) {
 	return nil
 }
 
-func roleRemotePolicies(remotePolicies []string, role *roleEntry) (err error) {
-	role.RemotePolicies = make([]*remotePolicy, len(remotePolicies))
-	for i, strPolicy := range remotePolicies {
-		policy := &remotePolicy{}
-		kvPairs := strings.Split(strPolicy, ",")
-		for _, kvPair := range kvPairs {
-			kvFields := strings.Split(kvPair, ":")
-			if len(kvFields) != 2 {
-				return fmt.Errorf("unable to recognize pair in %s", kvPair)
+// parseInlinePolicies accepts a policy document as a map, a map in the shape
+// returned on read (with a "policy_document" key), or the legacy JSON string
+// holding either a single document or a list of them.
+func parseInlinePolicies(rawPolicy interface{}) ([]map[string]interface{}, error) {
+	switch policy := rawPolicy.(type) {
+	case string:
+		policy = strings.TrimSpace(policy)
+		if strings.HasPrefix(policy, "[") {
+			var policyDocs []map[string]interface{}
+			if err := json.Unmarshal([]byte(policy), &policyDocs); err != nil {
+				return nil, err
 			}
-			switch kvFields[0] {
-			case "policy_name":
-				policy.PolicyName = kvFields[1]
-			case "scope":
-				policy.Scope = kvFields[1]
-			default:
-				return fmt.Errorf("invalid key: %s", kvFields[0])
+			return policyDocs, nil
+		}
+		var policyDoc map[string]interface{}
+		if err := json.Unmarshal([]byte(policy), &policyDoc); err != nil {
+			return nil, err
+		}
+		return parseInlinePolicies(policyDoc)
+	case map[string]interface{}:
+		rawDoc, ok := policy["policy_document"]
+		if !ok {
+			return []map[string]interface{}{policy}, nil
+		}
+		switch doc := rawDoc.(type) {
+		case string:
+			var policyDoc map[string]interface{}
+			if err := json.Unmarshal([]byte(doc), &policyDoc); err != nil {
+				return nil, err
 			}
+			return []map[string]interface{}{policyDoc}, nil
+		case map[string]interface{}:
+			return []map[string]interface{}{doc}, nil
+		}
+		return nil, fmt.Errorf("unable to recognize policy_document %v", rawDoc)
+	}
+	return nil, fmt.Errorf("unable to recognize inline policy %v", rawPolicy)
+}
+
+func findInlinePolicy(policies []*inlinePolicy, policyDoc map[string]interface{}) *inlinePolicy {
+	for _, policy := range policies {
+		if reflect.DeepEqual(policy.PolicyDocument, policyDoc) {
+			return policy
+		}
+	}
+	return nil
+}
+
+func removeInlinePolicy(policies []*inlinePolicy, policy *inlinePolicy) []*inlinePolicy {
+	result := make([]*inlinePolicy, 0, len(policies))
+	for _, p := range policies {
+		if p != policy {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func roleRemotePolicies(rawPolicies []interface{}, role *roleEntry) (err error) {
+	role.RemotePolicies = make([]*remotePolicy, len(rawPolicies))
+	for i, rawPolicy := range rawPolicies {
+		var policy *remotePolicy
+		switch p := rawPolicy.(type) {
+		case string:
+			policy, err = parseRemotePolicyString(p)
+		case map[string]interface{}:
+			policy, err = parseRemotePolicyMap(p)
+		default:
+			err = fmt.Errorf("unable to recognize remote policy %v", rawPolicy)
+		}
+		if err != nil {
+			return err
 		}
 		if policy.PolicyName == "" {
-			return fmt.Errorf("policy name is required in %s", strPolicy)
+			return fmt.Errorf("policy name is required in %v", rawPolicy)
 		}
 		if policy.Scope == "" {
-			return fmt.Errorf("policy scope is required in %s", strPolicy)
+			return fmt.Errorf("policy scope is required in %v", rawPolicy)
 		}
 		role.RemotePolicies[i] = policy
 	}
 	return nil
 }
 
+// parseRemotePolicyString parses the legacy "policy_name:X,scope:Y" form.
+func parseRemotePolicyString(strPolicy string) (*remotePolicy, error) {
+	policy := &remotePolicy{}
+	kvPairs := strings.Split(strPolicy, ",")
+	for _, kvPair := range kvPairs {
+		kvFields := strings.SplitN(kvPair, ":", 2)
+		if len(kvFields) != 2 {
+			return nil, fmt.Errorf("unable to recognize pair in %s", kvPair)
+		}
+		switch strings.TrimSpace(kvFields[0]) {
+		case "policy_name":
+			policy.PolicyName = kvFields[1]
+		case "scope":
+			policy.Scope = kvFields[1]
+		default:
+			return nil, fmt.Errorf("invalid key: %s", kvFields[0])
+		}
+	}
+	return policy, nil
+}
+
+func parseRemotePolicyMap(mapPolicy map[string]interface{}) (*remotePolicy, error) {
+	policy := &remotePolicy{}
+	for key, value := range mapPolicy {
+		switch key {
+		case "policy_name":
+			policy.PolicyName = cast.ToString(value)
+		case "scope":
+			policy.Scope = cast.ToString(value)
+		case "policy_id":
+			// Returned on read and resolved again when credentials are issued.
+		default:
+			return nil, fmt.Errorf("invalid key: %s", key)
+		}
+	}
+	return policy, nil
+}
+
 func (b *backend) pathRoleWrite(ctx context.Context,
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	roleName := data.Get("name").(string)
 	if roleName == "" {
 		return nil, fmt.Errorf("name is required")
 	}
+
+	b.roleLock.Lock()
+	defer b.roleLock.Unlock()
+
 	role, err := readRole(ctx, req.Storage, roleName)
 	if err != nil {
 		return nil, err
@@ -216,16 +438,17 @@ func (b *backend) pathRoleWrite(ctx context.Context,
 	if raw, ok := data.GetOk("role_arn"); ok {
 		role.RoleARN = raw.(string)
 	}
+	if raw, ok := data.GetOk("source_role_arn"); ok {
+		role.SourceRoleARNs = raw.([]string)
+	}
 	if raw, ok := data.GetOk("inline_policies"); ok {
-		policyDocsStr := raw.(string)
-		err = roleInlinePolicies(policyDocsStr, role)
+		err = roleInlinePolicies(raw.([]interface{}), role)
 		if err != nil {
 			return nil, err
 		}
 	}
 	if raw, ok := data.GetOk("remote_policies"); ok {
-		remotePolicies := raw.([]string)
-		err = roleRemotePolicies(remotePolicies, role)
+		err = roleRemotePolicies(raw.([]interface{}), role)
 		if err != nil {
 			return nil, err
 		}
@@ -236,27 +459,56 @@ func (b *backend) pathRoleWrite(ctx context.Context,
 	if raw, ok := data.GetOk("max_ttl"); ok {
 		role.MaxTTL = time.Duration(raw.(int)) * time.Second
 	}
-	if role.MaxTTL > 0 && role.TTL > role.MaxTTL {
-		return nil, fmt.Errorf("ttl exceeds max_ttl")
+	if raw, ok := data.GetOk("external_id"); ok {
+		role.ExternalId = raw.(string)
 	}
-	if role.Type() == roleTypeSTS {
-		if len(role.RemotePolicies) > 0 {
-			return nil, fmt.Errorf("remote_policies must be blank when an arn is present")
-		}
-		if len(role.InlinePolicies) > 0 {
-			return nil, fmt.Errorf("inline_policies must be blank when an arn is present")
-		}
-	} else if len(role.InlinePolicies)+len(role.RemotePolicies) == 0 {
-		return nil, fmt.Errorf("must include an arn, or at least one of inline_policies or remote_policies")
+	if raw, ok := data.GetOk("allowed_external_ids"); ok {
+		role.AllowedExternalIds = raw.([]string)
+	}
+	if raw, ok := data.GetOk("external_id_required"); ok {
+		role.ExternalIdRequired = raw.(bool)
+	}
+	if raw, ok := data.GetOk("sts_renewable"); ok {
+		role.STSRenewable = raw.(bool)
+	}
+	if raw, ok := data.GetOk("sts_revoke_sessions"); ok {
+		role.STSRevokeSessions = raw.(bool)
+	}
+	if raw, ok := data.GetOk("wait_for_propagation"); ok {
+		role.WaitForPropagation = raw.(bool)
+	}
+	if raw, ok := data.GetOk("propagation_timeout"); ok {
+		role.PropagationTimeout = time.Duration(raw.(int)) * time.Second
+	}
+	if raw, ok := data.GetOk("pool_size"); ok {
+		role.PoolSize = raw.(int)
+	}
+	if raw, ok := data.GetOk("max_concurrent_leases"); ok {
+		role.MaxConcurrentLeases = raw.(int)
+	}
+	if raw, ok := data.GetOk("issue_rate_limit"); ok {
+		role.IssueRateLimit = raw.(int)
+	}
+	if raw, ok := data.GetOk("account"); ok {
+		role.Account = raw.(string)
 	}
-	err = saveRole(ctx, role, req.Storage, roleName)
+	if raw, ok := data.GetOk("tags"); ok {
+		role.Tags = raw.(map[string]string)
+	}
+	if err := validateRole(role); err != nil {
+		return nil, err
+	}
+	if err := validateRoleAccount(ctx, req.Storage, role); err != nil {
+		return nil, err
+	}
+	err = storeRole(ctx, req, role, roleName)
 	if err != nil {
 		return nil, err
 	}
 	resp := &logical.Response{}
-	if role.Type() == roleTypeSTS && (role.TTL > 0 || role.MaxTTL > 0) {
-		resp.AddWarning("role_arn is set so ttl and max_ttl will " +
-			"be ignored because they're not editable on STS tokens")
+	if role.Type() == roleTypeSTS && role.TTL > 0 {
+		resp.AddWarning("role_arn is set so ttl will be ignored, " +
+			"STS credentials last for the ttl requested on creds or the STS default")
 	}
 	if role.TTL > b.System().MaxLeaseTTL() {
 		resp.AddWarning(fmt.Sprintf("ttl of %d exceeds the system max ttl of %d, "+
@@ -268,13 +520,183 @@ func (b *backend) pathRoleWrite(ctx context.Context,
 	return nil, nil
 }
 
+// validateRoleAccount checks that the role's named account exists, and checks
+// the role against the credentials of its account when they are configured.
+func validateRoleAccount(ctx context.Context, s logical.Storage, role *roleEntry) error {
+	if role.Account == "" && !role.STSRevokeSessions {
+		return nil
+	}
+	creds, err := readAccountConfig(ctx, s, role.Account)
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		if role.Account != "" {
+			return errors.New(credsNotConfigured(role.Account))
+		}
+		return nil
+	}
+	if role.STSRevokeSessions {
+		return checkRevocationAccount(creds, role.RoleARN)
+	}
+	return nil
+}
+
+func validateRole(role *roleEntry) error {
+	if role.MaxTTL > 0 && role.TTL > role.MaxTTL {
+		return fmt.Errorf("ttl exceeds max_ttl")
+	}
+	if role.Type() != roleTypeSTS &&
+		(role.ExternalId != "" || len(role.AllowedExternalIds) > 0 || role.ExternalIdRequired) {
+		return fmt.Errorf("external_id, allowed_external_ids and external_id_required require role_arn")
+	}
+	if len(role.SourceRoleARNs) > 0 && role.Type() != roleTypeSTS {
+		return fmt.Errorf("source_role_arn requires role_arn")
+	}
+	if role.STSRenewable && role.Type() != roleTypeSTS {
+		return fmt.Errorf("sts_renewable requires role_arn")
+	}
+	if (role.WaitForPropagation || role.PropagationTimeout != 0) && role.Type() != roleTypeCAM {
+		return fmt.Errorf("wait_for_propagation and propagation_timeout can't be used with role_arn")
+	}
+	if role.PropagationTimeout < 0 {
+		return fmt.Errorf("propagation_timeout must not be negative")
+	}
+	if role.PoolSize != 0 && role.Type() != roleTypeCAM {
+		return fmt.Errorf("pool_size can't be used with role_arn")
+	}
+	if role.PoolSize < 0 || role.PoolSize > maxPoolSize {
+		return fmt.Errorf("pool_size must be between 0 and %d", maxPoolSize)
+	}
+	limits := role.leaseLimits()
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	if role.STSRevokeSessions {
+		if role.Type() != roleTypeSTS {
+			return fmt.Errorf("sts_revoke_sessions requires role_arn")
+		}
+		if _, _, err := parseRoleARN(role.RoleARN); err != nil {
+			return err
+		}
+		// The deny policy is managed with the configured credentials, which
+		// can't reach a role only trusted by a hub role.
+		if len(role.SourceRoleARNs) > 0 {
+			return fmt.Errorf("sts_revoke_sessions can't be used with source_role_arn")
+		}
+	}
+	if role.Type() == roleTypeSTS {
+		if len(role.RemotePolicies) > 0 {
+			return fmt.Errorf("remote_policies must be blank when an arn is present")
+		}
+		if len(role.InlinePolicies) > 0 {
+			return fmt.Errorf("inline_policies must be blank when an arn is present")
+		}
+	} else if len(role.InlinePolicies)+len(role.RemotePolicies) == 0 {
+		return fmt.Errorf("must include an arn, or at least one of inline_policies or remote_policies")
+	}
+	return nil
+}
+
 func (b *backend) pathRolesList(ctx context.Context,
-	req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	entries, err := req.Storage.List(ctx, rolePath)
 	if err != nil {
 		return nil, err
 	}
-	return logical.ListResponse(entries), nil
+	detailed := data.Get("detailed").(bool)
+	tags := data.Get("tag").(map[string]string)
+	rType := roleTypeUnknown
+	if raw, ok := data.GetOk("type"); ok {
+		if rType, err = parseRoleType(raw.(string)); err != nil {
+			return nil, err
+		}
+	}
+	after := data.Get("after").(string)
+	limit := data.Get("limit").(int)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	sort.Strings(entries)
+	keys := make([]string, 0, len(entries))
+	keyInfo := make(map[string]interface{})
+	for _, roleName := range entries {
+		if limit > 0 && len(keys) >= limit {
+			break
+		}
+		if after != "" && roleName <= after {
+			continue
+		}
+		if !detailed && rType == roleTypeUnknown && len(tags) == 0 {
+			keys = append(keys, roleName)
+			continue
+		}
+		role, err := readRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			continue
+		}
+		if rType != roleTypeUnknown && role.Type() != rType {
+			continue
+		}
+		if !role.hasTags(tags) {
+			continue
+		}
+		keys = append(keys, roleName)
+		if detailed {
+			keyInfo[roleName] = roleListInfo(role)
+		}
+	}
+	if detailed {
+		return logical.ListResponseWithInfo(keys, keyInfo), nil
+	}
+	return logical.ListResponse(keys), nil
+}
+
+func (r *roleEntry) hasTags(tags map[string]string) bool {
+	for key, value := range tags {
+		if tag, ok := r.Tags[key]; !ok || tag != value {
+			return false
+		}
+	}
+	return true
+}
+
+func roleListInfo(role *roleEntry) map[string]interface{} {
+	remotePolicies := make([]string, len(role.RemotePolicies))
+	for i, policy := range role.RemotePolicies {
+		remotePolicies[i] = policy.PolicyName
+	}
+	inlinePolicies := make([]string, len(role.InlinePolicies))
+	for i, policy := range role.InlinePolicies {
+		inlinePolicies[i] = policy.UUID
+	}
+	return map[string]interface{}{
+		"role_type":             role.Type().String(),
+		"role_arn":              role.RoleARN,
+		"source_role_arn":       role.SourceRoleARNs,
+		"remote_policies":       remotePolicies,
+		"inline_policies":       inlinePolicies,
+		"ttl":                   role.TTL / time.Second,
+		"max_ttl":               role.MaxTTL / time.Second,
+		"external_id_set":       role.ExternalId != "",
+		"allowed_external_ids":  role.AllowedExternalIds,
+		"external_id_required":  role.ExternalIdRequired,
+		"sts_renewable":         role.STSRenewable,
+		"sts_revoke_sessions":   role.STSRevokeSessions,
+		"wait_for_propagation":  role.WaitForPropagation,
+		"propagation_timeout":   role.PropagationTimeout / time.Second,
+		"pool_size":             role.PoolSize,
+		"max_concurrent_leases": role.MaxConcurrentLeases,
+		"issue_rate_limit":      role.IssueRateLimit,
+		"account":               role.Account,
+		"tags":                  role.Tags,
+		"version":               role.Version,
+		"last_modified":         role.LastModified,
+	}
 }
 
 func (b *backend) pathRoleRead(ctx context.Context,
@@ -292,24 +714,67 @@ func (b *backend) pathRoleRead(ctx context.Context,
 		return nil, nil
 	}
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"role_arn":        role.RoleARN,
-			"remote_policies": role.RemotePolicies,
-			"inline_policies": role.InlinePolicies,
-			"ttl":             role.TTL / time.Second,
-			"max_ttl":         role.MaxTTL / time.Second,
-		},
+		Data: roleResponseData(role),
 	}, nil
 }
 
+func roleResponseData(role *roleEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"role_arn":              role.RoleARN,
+		"source_role_arn":       role.SourceRoleARNs,
+		"remote_policies":       role.RemotePolicies,
+		"inline_policies":       role.InlinePolicies,
+		"ttl":                   role.TTL / time.Second,
+		"max_ttl":               role.MaxTTL / time.Second,
+		"external_id_set":       role.ExternalId != "",
+		"allowed_external_ids":  role.AllowedExternalIds,
+		"external_id_required":  role.ExternalIdRequired,
+		"sts_renewable":         role.STSRenewable,
+		"sts_revoke_sessions":   role.STSRevokeSessions,
+		"wait_for_propagation":  role.WaitForPropagation,
+		"propagation_timeout":   role.PropagationTimeout / time.Second,
+		"pool_size":             role.PoolSize,
+		"max_concurrent_leases": role.MaxConcurrentLeases,
+		"issue_rate_limit":      role.IssueRateLimit,
+		"account":               role.Account,
+		"tags":                  role.Tags,
+		"version":               role.Version,
+		"last_modified":         role.LastModified,
+	}
+}
+
 func (b *backend) pathRoleDelete(ctx context.Context,
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.roleLock.Lock()
+	defer b.roleLock.Unlock()
+
 	if err := req.Storage.Delete(ctx, rolePath+data.Get("name").(string)); err != nil {
 		return nil, err
 	}
 	return nil, nil
 }
 
+// storeRole saves the role under a new version number and records that version
+// in the role's history. The caller must hold roleLock.
+func storeRole(ctx context.Context, req *logical.Request, role *roleEntry, roleName string) error {
+	version, err := latestRoleVersion(ctx, req.Storage, roleName)
+	if err != nil {
+		return err
+	}
+	role.Version = version + 1
+	role.LastModified = time.Now().UTC()
+	if err := saveRole(ctx, role, req.Storage, roleName); err != nil {
+		return err
+	}
+	return saveRoleVersion(ctx, req.Storage, roleName, &roleVersion{
+		Version:     role.Version,
+		CreatedAt:   role.LastModified,
+		EntityID:    req.EntityID,
+		DisplayName: req.DisplayName,
+		Role:        role,
+	})
+}
+
 func saveRole(ctx context.Context, role *roleEntry, s logical.Storage, roleName string) error {
 	entry, err := logical.StorageEntryJSON(rolePath+roleName, role)
 	if err != nil {
@@ -338,7 +803,12 @@ func readRole(ctx context.Context, s logical.Storage, roleName string) (*roleEnt
 
 const pathListRolesHelpSyn = "List the existing roles in this backend."
 
-const pathListRolesHelpDesc = "Roles will be listed by the role name."
+const pathListRolesHelpDesc = `
+Roles will be listed by the role name. With "detailed" set, the type, TTLs,
+policies, ARN, tags and last-modified time of each role are returned too.
+Roles can be filtered by "type" and "tag", and paged through with "after"
+and "limit".
+`
 
 const pathRolesHelpSyn = `
 Read, write and reference policies and roles that API keys or STS credentials can be made for.
@@ -361,4 +831,7 @@ backend is mounted at "tencentcloud" and you create a role at "tencentcloud/role
 then a user could request access credentials at "tencentcloud/creds/deploy".
 
 To validate the keys, attempt to read an secret after writing the policy.
+
+Every write stores a new version of the role. The most recent versions are kept
+and can be read at "role/<name>/versions".
 `
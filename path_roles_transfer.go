@@ -0,0 +1,322 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	conflictModeSkip      = "skip"
+	conflictModeOverwrite = "overwrite"
+	conflictModeFail      = "fail"
+)
+
+var roleNameRegex = regexp.MustCompile(`^\w(([\w-.]+)?\w)?$`)
+
+func pathExportRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/export$",
+		Fields: map[string]*framework.FieldSchema{
+			"names": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Names of the roles to export. Defaults to all roles.",
+			},
+			"type": {
+				Type:        framework.TypeString,
+				Description: `Only export roles of this type, "cam" or "sts".`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathRolesExport,
+			},
+		},
+		HelpSynopsis:    pathExportRolesHelpSyn,
+		HelpDescription: pathExportRolesHelpDesc,
+	}
+}
+
+func pathImportRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/import$",
+		Fields: map[string]*framework.FieldSchema{
+			"roles": {
+				Type: framework.TypeMap,
+				Description: `JSON object of role names to roles, in the format returned by "roles/export".
+A string, such as an HCL document, is rejected.`,
+			},
+			"conflict_mode": {
+				Type:        framework.TypeString,
+				Default:     conflictModeFail,
+				Description: `What to do when a role already exists: "skip", "overwrite" or "fail".`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRolesImport,
+			},
+		},
+		HelpSynopsis:    pathImportRolesHelpSyn,
+		HelpDescription: pathImportRolesHelpDesc,
+	}
+}
+
+func (b *backend) pathRolesExport(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	names := data.Get("names").([]string)
+	if len(names) == 0 {
+		var err error
+		names, err = req.Storage.List(ctx, rolePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rType := roleTypeUnknown
+	if raw, ok := data.GetOk("type"); ok {
+		var err error
+		rType, err = parseRoleType(raw.(string))
+		if err != nil {
+			return nil, err
+		}
+	}
+	roles := make(map[string]interface{}, len(names))
+	for _, roleName := range names {
+		role, err := readRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return nil, fmt.Errorf("role %s does not exist", roleName)
+		}
+		if rType != roleTypeUnknown && role.Type() != rType {
+			continue
+		}
+		// The external ID is never returned, only whether there is one; an
+		// import keeps the value already set on the target role.
+		externalIdSet := role.ExternalId != ""
+		role.ExternalId = ""
+		roleJSON, err := jsonutil.EncodeJSON(role)
+		if err != nil {
+			return nil, err
+		}
+		exported := make(map[string]interface{})
+		if err := jsonutil.DecodeJSON(roleJSON, &exported); err != nil {
+			return nil, err
+		}
+		delete(exported, "external_id")
+		if externalIdSet {
+			exported["external_id_set"] = true
+		}
+		roles[roleName] = exported
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"roles": roles,
+		},
+	}, nil
+}
+
+func (b *backend) pathRolesImport(ctx context.Context,
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	conflictMode := data.Get("conflict_mode").(string)
+	if !strutil.StrListContains([]string{conflictModeSkip, conflictModeOverwrite, conflictModeFail}, conflictMode) {
+		return nil, fmt.Errorf("unknown conflict_mode: %s", conflictMode)
+	}
+	rawRoles := data.Get("roles").(map[string]interface{})
+	if len(rawRoles) == 0 {
+		return nil, fmt.Errorf("roles is required")
+	}
+
+	b.roleLock.Lock()
+	defer b.roleLock.Unlock()
+
+	// Validate every role and resolve every conflict before anything is
+	// written, so a bad document leaves the mount untouched.
+	roles := make(map[string]*roleEntry, len(rawRoles))
+	snapshots := make(map[string]*roleSnapshot, len(rawRoles))
+	var skipped []string
+	for roleName, raw := range rawRoles {
+		if roleName != strings.ToLower(roleName) || !roleNameRegex.MatchString(roleName) {
+			return nil, fmt.Errorf("invalid role name: %s", roleName)
+		}
+		role, externalIdSet, err := decodeImportedRole(raw)
+		if err != nil {
+			return nil, fmt.Errorf("role %s: %s", roleName, err)
+		}
+		if err := validateRole(role); err != nil {
+			return nil, fmt.Errorf("role %s: %s", roleName, err)
+		}
+		if err := validateRoleAccount(ctx, req.Storage, role); err != nil {
+			return nil, fmt.Errorf("role %s: %s", roleName, err)
+		}
+		existing, err := readRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			switch conflictMode {
+			case conflictModeFail:
+				return nil, fmt.Errorf("role %s already exists", roleName)
+			case conflictModeSkip:
+				skipped = append(skipped, roleName)
+				continue
+			}
+			if role.ExternalId == "" {
+				role.ExternalId = existing.ExternalId
+			}
+		}
+		if externalIdSet && role.ExternalId == "" {
+			return nil, fmt.Errorf("role %s: the exported role has an external_id, which is never exported; "+
+				"set external_id in the document or import over a role that has one", roleName)
+		}
+		snapshot, err := snapshotRole(ctx, req.Storage, roleName, existing)
+		if err != nil {
+			return nil, err
+		}
+		snapshots[roleName] = snapshot
+		roles[roleName] = role
+	}
+
+	var imported []string
+	for roleName, role := range roles {
+		if err := storeRole(ctx, req, role, roleName); err != nil {
+			// The failed role may have been written without its version.
+			b.restoreRoles(ctx, req.Storage, append(imported, roleName), snapshots)
+			return nil, err
+		}
+		imported = append(imported, roleName)
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"imported": strutil.RemoveDuplicates(imported, false),
+			"skipped":  strutil.RemoveDuplicates(skipped, false),
+		},
+	}, nil
+}
+
+// roleSnapshot holds a role and its versions as they were before an import,
+// so a failed import can put them back. role is nil if the role didn't exist.
+type roleSnapshot struct {
+	role     *roleEntry
+	versions []*logical.StorageEntry
+}
+
+func snapshotRole(ctx context.Context, s logical.Storage, roleName string, role *roleEntry) (*roleSnapshot, error) {
+	versions, err := listRoleVersions(ctx, s, roleName)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &roleSnapshot{role: role}
+	for _, version := range versions {
+		entry, err := s.Get(ctx, roleVersionStoragePath(roleName, version))
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			snapshot.versions = append(snapshot.versions, entry)
+		}
+	}
+	return snapshot, nil
+}
+
+// restore replaces the role and its versions with the snapshot, undoing both
+// the new version an import wrote and any old version it pruned.
+func (r *roleSnapshot) restore(ctx context.Context, s logical.Storage, roleName string) error {
+	versions, err := listRoleVersions(ctx, s, roleName)
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		if err := s.Delete(ctx, roleVersionStoragePath(roleName, version)); err != nil {
+			return err
+		}
+	}
+	for _, entry := range r.versions {
+		if err := s.Put(ctx, entry); err != nil {
+			return err
+		}
+	}
+	if r.role == nil {
+		return s.Delete(ctx, rolePath+roleName)
+	}
+	return saveRole(ctx, r.role, s, roleName)
+}
+
+// restoreRoles puts back the roles an import overwrote or created before it
+// failed, along with their versions.
+func (b *backend) restoreRoles(ctx context.Context, s logical.Storage,
+	roleNames []string, snapshots map[string]*roleSnapshot) {
+	for _, roleName := range roleNames {
+		if err := snapshots[roleName].restore(ctx, s, roleName); err != nil && b.Logger().IsError() {
+			b.Logger().Error(fmt.Sprintf("unable to restore role %s after failed import", roleName), "error", err)
+		}
+	}
+}
+
+// decodeImportedRole returns the role and whether the export marked it as
+// having an external ID that was left out.
+func decodeImportedRole(raw interface{}) (*roleEntry, bool, error) {
+	roleJSON, err := jsonutil.EncodeJSON(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	role := &roleEntry{}
+	if err := jsonutil.DecodeJSON(roleJSON, role); err != nil {
+		return nil, false, fmt.Errorf("malformed role: %w", err)
+	}
+	var marker struct {
+		ExternalIdSet bool `json:"external_id_set"`
+	}
+	if err := jsonutil.DecodeJSON(roleJSON, &marker); err != nil {
+		return nil, false, fmt.Errorf("malformed role: %w", err)
+	}
+	role.Account = strings.ToLower(role.Account)
+	for _, policy := range role.InlinePolicies {
+		if policy == nil || policy.PolicyDocument == nil {
+			return nil, false, fmt.Errorf("inline policy is missing its policy_document")
+		}
+		if policy.UUID == "" {
+			uid, err := uuid.GenerateUUID()
+			if err != nil {
+				return nil, false, err
+			}
+			policy.UUID = strings.Replace(uid, "-", "", -1)
+		}
+	}
+	for _, policy := range role.RemotePolicies {
+		if policy == nil || policy.PolicyName == "" || policy.Scope == "" {
+			return nil, false, fmt.Errorf("remote policy requires policy_name and scope")
+		}
+	}
+	return role, marker.ExternalIdSet, nil
+}
+
+const pathExportRolesHelpSyn = "Export roles as a single document."
+
+const pathExportRolesHelpDesc = `
+Returns the selected roles, keyed by name, in the same format they are stored
+in. The result can be written to "roles/import" on another mount to promote
+role definitions between clusters. Fixed external IDs are left out, and
+external_id_set marks the roles that have one.
+`
+
+const pathImportRolesHelpSyn = "Import roles from a document produced by roles/export."
+
+const pathImportRolesHelpDesc = `
+The roles must be a JSON object, such as the "roles" field of an export; HCL
+and JSON encoded as a string are not accepted. Every role in the document is validated, and conflicts with existing roles are
+resolved, before any role is written. With conflict_mode "fail" (the default)
+the import is rejected if any role exists, with "skip" existing roles are left
+as they are, and with "overwrite" they are replaced, keeping the external ID
+of the replaced role when the document has none. A role marked with
+external_id_set that would end up without an external ID is rejected. Each
+imported role is stored as a new role version.
+`
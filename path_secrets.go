@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
@@ -51,8 +52,12 @@ func (b *backend) operationRenew(ctx context.Context,
 	switch rType {
 
 	case roleTypeSTS:
-		// STS already has a lifetime, and we don'nameOfRoleType support renewing it.
-		return nil, nil
+		// STS already has a lifetime, so it is only renewed for roles that opted
+		// in to having the role assumed again.
+		if !req.Secret.Renewable {
+			return nil, nil
+		}
+		return b.renewSTS(ctx, req)
 
 	case roleTypeCAM:
 		roleName, err := getStringValue(req.Secret.InternalData, "role_name")
@@ -75,9 +80,24 @@ func (b *backend) operationRenew(ctx context.Context,
 		if role.TTL != 0 {
 			resp.Secret.TTL = role.TTL
 		}
+		// Keep the shorter lifetime the lease was asked for at issue time.
+		if ttlRaw, ok := req.Secret.InternalData["ttl"]; ok {
+			if ttl := time.Duration(cast.ToInt64(ttlRaw)) * time.Second; ttl > 0 {
+				resp.Secret.TTL = ttl
+			}
+		}
 		if role.MaxTTL != 0 {
 			resp.Secret.MaxTTL = role.MaxTTL
 		}
+		if secretId, _ := req.Secret.InternalData["secret_id"].(string); secretId != "" {
+			ttl := resp.Secret.TTL
+			if ttl == 0 {
+				ttl = b.System().DefaultLeaseTTL()
+			}
+			if err := b.extendIssued(ctx, req.Storage, secretId, ttl); err != nil {
+				return nil, err
+			}
+		}
 		return resp, nil
 
 	default:
@@ -85,8 +105,97 @@ func (b *backend) operationRenew(ctx context.Context,
 	}
 }
 
+// renewSTS assumes the role again with the session name and external ID the
+// lease was issued with, and returns the fresh temporary credentials. The new
+// credentials never outlive the lease's max TTL.
+func (b *backend) renewSTS(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+	roleName, err := getStringValue(req.Secret.InternalData, "role_name")
+	if err != nil {
+		return nil, err
+	}
+	role, err := readRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role %s has been deleted so no further renewals are allowed", roleName)
+	}
+	if !role.STSRenewable {
+		return nil, fmt.Errorf("role %s no longer allows STS credentials to be renewed", roleName)
+	}
+	account := leaseAccount(req.Secret.InternalData)
+	accountClient, err := b.clientsFor(ctx, req.Storage, account)
+	if err != nil {
+		return nil, err
+	}
+	if accountClient == nil {
+		return nil, fmt.Errorf("unable to renew STS credentials because %s", credsNotConfigured(account))
+	}
+	roleARN, err := getStringValue(req.Secret.InternalData, "role_arn")
+	if err != nil {
+		return nil, err
+	}
+	roleSessionName, err := getStringValue(req.Secret.InternalData, "role_session_name")
+	if err != nil {
+		return nil, err
+	}
+	externalId, err := getStringValue(req.Secret.InternalData, "external_id")
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(cast.ToInt64(req.Secret.InternalData["ttl"])) * time.Second
+	if ttl == 0 {
+		ttl = defaultSTSDuration
+	}
+	if req.Secret.MaxTTL > 0 {
+		remaining := req.Secret.IssueTime.Add(req.Secret.MaxTTL).Sub(time.Now())
+		if remaining <= 0 {
+			return nil, fmt.Errorf("lease has reached its max_ttl")
+		}
+		if ttl > remaining {
+			ttl = remaining
+		}
+	}
+	sourceRoleARNs := cast.ToStringSlice(req.Secret.InternalData["source_role_arn"])
+	credsData, expiration, err := b.assumeRole(accountClient, sourceRoleARNs, roleSessionName, roleARN, externalId, ttl)
+	if err != nil {
+		return nil, err
+	}
+	resp := &logical.Response{Secret: req.Secret, Data: credsData}
+	resp.Secret.TTL = expiration.Sub(time.Now())
+	// The credentials replaced by the renewal stay indexed until they expire.
+	previousSecretId := cast.ToString(resp.Secret.InternalData["secret_id"])
+	resp.Secret.InternalData["secret_id"] = credsData["secret_id"]
+	if err := b.recordRenewedIssued(ctx, req, resp, previousSecretId); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (b *backend) operationRevoke(ctx context.Context,
-	req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	req *logical.Request, _ *framework.FieldData) (resp *logical.Response, err error) {
+	// Revoked leases stop counting against max_concurrent_leases and are
+	// removed from the issued index.
+	defer func() {
+		if err != nil {
+			return
+		}
+		if secretId, _ := req.Secret.InternalData["secret_id"].(string); secretId != "" {
+			if deleteErr := req.Storage.Delete(ctx, issuedPath+secretId); deleteErr != nil && b.Logger().IsError() {
+				b.Logger().Error(fmt.Sprintf("unable to remove %s from the issued index", secretId), "error", deleteErr)
+			}
+		}
+		// Leases issued before counting began, or by an older version, were
+		// never counted.
+		roleName, _ := req.Secret.InternalData["role_name"].(string)
+		if roleName == "" || !cast.ToBool(req.Secret.InternalData[leaseCountedKey]) {
+			return
+		}
+		if releaseErr := b.releaseLease(ctx, req.Storage, roleName); releaseErr != nil && b.Logger().IsError() {
+			b.Logger().Error(fmt.Sprintf("unable to release lease count of role %s", roleName), "error", releaseErr)
+		}
+	}()
 	roleTypeRaw, ok := req.Secret.InternalData["role_type"]
 	if !ok {
 		return nil, errors.New("role_type missing from secret")
@@ -101,63 +210,123 @@ func (b *backend) operationRevoke(ctx context.Context,
 	}
 	switch rType {
 	case roleTypeSTS:
-		return nil, nil
-	case roleTypeCAM:
-		creds, err := readCredConfig(ctx, req.Storage)
+		// Temporary credentials can't be deleted, but roles that opted in deny
+		// the session until the credentials would have expired anyway.
+		revokeSessions, _ := req.Secret.InternalData["revoke_sessions"].(bool)
+		if !revokeSessions {
+			return nil, nil
+		}
+		roleARN, err := getStringValue(req.Secret.InternalData, "role_arn")
 		if err != nil {
 			return nil, err
 		}
-		if creds == nil {
-			return nil, errors.New("unable to delete access key because no credentials are configured")
+		roleSessionName, err := getStringValue(req.Secret.InternalData, "role_session_name")
+		if err != nil {
+			return nil, err
 		}
-		client, err := clients.NewCAMClient(b.profile, creds.SecretId, creds.SecretKey)
+		return nil, b.revokeSTSSession(ctx, req.Storage, leaseAccount(req.Secret.InternalData),
+			roleARN, roleSessionName)
+	case roleTypeCAM:
+		secret_id, err := getStringValue(req.Secret.InternalData, "secret_id")
 		if err != nil {
 			return nil, err
 		}
-		userName, err := getStringValue(req.Secret.InternalData, "username")
+		// Credentials revoked through lookup have nothing left to delete.
+		issued, err := readIssued(ctx, req.Storage, secret_id)
 		if err != nil {
 			return nil, err
 		}
-		uin, err := getStringValue(req.Secret.InternalData, "uin")
+		if issued != nil && !issued.RevokedAt.IsZero() {
+			return nil, nil
+		}
+		account := leaseAccount(req.Secret.InternalData)
+		accountClient, err := b.clientsFor(ctx, req.Storage, account)
 		if err != nil {
 			return nil, err
 		}
-		secret_id, err := getStringValue(req.Secret.InternalData, "secret_id")
+		if accountClient == nil {
+			return nil, fmt.Errorf("unable to delete access key because %s", credsNotConfigured(account))
+		}
+		userName, err := getStringValue(req.Secret.InternalData, "username")
 		if err != nil {
 			return nil, err
 		}
-		apiErrs := &multierror.Error{}
-		uinInt := uint64(cast.ToInt64(uin))
-		if err := client.DeleteAccessKey(&secret_id, &uinInt); err != nil {
-			apiErrs = multierror.Append(apiErrs, err)
+		uin, err := getStringValue(req.Secret.InternalData, "uin")
+		if err != nil {
+			return nil, err
 		}
 		inlinePolicies, _ := getRemotePolicies(req.Secret.InternalData, "inline_policies")
-		for _, inlinePolicy := range inlinePolicies {
-			if err := client.DetachUserPolicy(&(inlinePolicy.PolicyId), &uinInt); err != nil {
-				apiErrs = multierror.Append(apiErrs, err)
-			}
-			if err := client.DeletePolicy([]*uint64{&(inlinePolicy.PolicyId)}); err != nil {
-				apiErrs = multierror.Append(apiErrs, err)
-			}
+		remotePolicies, _ := getRemotePolicies(req.Secret.InternalData, "remote_policies")
+		return nil, revokeCAMCredential(accountClient.cam, secret_id, userName,
+			uint64(cast.ToInt64(uin)), inlinePolicies, remotePolicies)
+
+	default:
+		return nil, fmt.Errorf("unrecognized role_type: %s", nameOfRoleType)
+	}
+}
+
+// revokeCAMCredential deletes the access key of a CAM secret along with its
+// user and the user's policies. Anything already deleted counts as revoked, so
+// a lease whose user was removed by hand can still be revoked.
+func revokeCAMCredential(client *clients.CAMClient, secretId, userName string, uin uint64,
+	inlinePolicies, remotePolicies []*remotePolicy) error {
+	apiErrs := &multierror.Error{}
+	if err := clients.IgnoreNotFound(client.DeleteAccessKey(&secretId, &uin)); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
+	}
+	if err := deleteCAMUser(client, userName, uin, inlinePolicies, remotePolicies); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
+	}
+	return apiErrs.ErrorOrNil()
+}
+
+// deleteCAMUser detaches and deletes the user's inline policies, detaches its
+// remote policies and deletes the user, ignoring what no longer exists. Remote
+// policies recorded without their ID are looked up by name.
+func deleteCAMUser(client *clients.CAMClient, userName string, uin uint64,
+	inlinePolicies, remotePolicies []*remotePolicy) error {
+	apiErrs := &multierror.Error{}
+	// Each inline policy is detached before it is deleted, but the policies
+	// themselves are handled concurrently.
+	if err := forEachPolicy(len(inlinePolicies), func(i int) error {
+		policyErrs := &multierror.Error{}
+		if err := clients.IgnoreNotFound(client.DetachUserPolicy(&(inlinePolicies[i].PolicyId), &uin)); err != nil {
+			policyErrs = multierror.Append(policyErrs, err)
 		}
-		remotePolicies, err := getRemotePolicies(req.Secret.InternalData, "remote_policies")
-		for _, remotePolicy := range remotePolicies {
-			policyId, err := getPolicyIdByRemotePol(remotePolicy, client)
-			if err != nil {
-				return nil, err
+		if err := clients.IgnoreNotFound(client.DeletePolicy([]*uint64{&(inlinePolicies[i].PolicyId)})); err != nil {
+			policyErrs = multierror.Append(policyErrs, err)
+		}
+		return policyErrs.ErrorOrNil()
+	}); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
+	}
+	if err := forEachPolicy(len(remotePolicies), func(i int) error {
+		policyId := &remotePolicies[i].PolicyId
+		if *policyId == 0 {
+			var err error
+			policyId, err = getPolicyIdByRemotePol(remotePolicies[i], client)
+			if errors.Is(err, errNoPolicyData) {
+				return nil
 			}
-			if err := client.DetachUserPolicy(policyId, &uinInt); err != nil {
-				apiErrs = multierror.Append(apiErrs, err)
+			if err != nil {
+				return err
 			}
 		}
-		if err := client.DeleteUser(&userName); err != nil {
-			apiErrs = multierror.Append(apiErrs, err)
-		}
-		return nil, apiErrs.ErrorOrNil()
-
-	default:
-		return nil, fmt.Errorf("unrecognized role_type: %s", nameOfRoleType)
+		return clients.IgnoreNotFound(client.DetachUserPolicy(policyId, &uin))
+	}); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
+	}
+	if err := clients.IgnoreNotFound(client.DeleteUser(&userName)); err != nil {
+		apiErrs = multierror.Append(apiErrs, err)
 	}
+	return apiErrs.ErrorOrNil()
+}
+
+// leaseAccount returns the account a lease was issued with. Leases issued
+// before named accounts existed use the credentials in config.
+func leaseAccount(internalData map[string]interface{}) string {
+	account, _ := internalData["account"].(string)
+	return account
 }
 
 func getStringValue(internalData map[string]interface{}, key string) (string, error) {
@@ -0,0 +1,56 @@
+package tencentcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
+)
+
+const (
+	defaultPropagationTimeout = 60 * time.Second
+
+	propagationInitialBackoff = 500 * time.Millisecond
+	propagationMaxBackoff     = 8 * time.Second
+)
+
+// waitForPropagation polls STS GetCallerIdentity with a new access key, backing
+// off between attempts, until Tencent Cloud accepts it or the timeout passes.
+func (b *backend) waitForPropagation(ctx context.Context, creds *credConfig,
+	secretId, secretKey string, timeout time.Duration) error {
+	if timeout == 0 {
+		timeout = defaultPropagationTimeout
+	}
+	config := b.stsConfig(creds)
+	client, err := clients.NewSTSClient(b.profile, &clients.Configuration{
+		SecretId:  secretId,
+		SecretKey: secretKey,
+		Region:    config.Region,
+		Endpoint:  config.Endpoint,
+	})
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	backoff := propagationInitialBackoff
+	for {
+		_, err := client.GetCallerIdentity()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("access key %s was not accepted within %s: %w", secretId, timeout, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > propagationMaxBackoff {
+			backoff = propagationMaxBackoff
+		}
+	}
+}
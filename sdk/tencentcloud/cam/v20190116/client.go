@@ -109,3 +109,98 @@ func (c *Client) DeleteAccessKey(request *DeleteAccessKeyRequest) (response *Del
 	err = c.Send(request, response)
 	return
 }
+
+func NewListAccessKeysRequest() (request *ListAccessKeysRequest) {
+	request = &ListAccessKeysRequest{
+		BaseRequest: &tchttp.BaseRequest{},
+	}
+	request.Init().WithApiInfo("cam", APIVersion, "ListAccessKeys")
+	return
+}
+
+func NewListAccessKeysResponse() (response *ListAccessKeysResponse) {
+	response = &ListAccessKeysResponse{
+		BaseResponse: &tchttp.BaseResponse{},
+	}
+	return
+}
+
+// ListAccessKeys
+// 列出指定CAM用户的访问密钥
+//
+// 可能返回的错误码:
+//  FAILEDOPERATION_ACCESSKEY = "FailedOperation.Accesskey"
+//  INVALIDPARAMETER_PARAMERROR = "InvalidParameter.ParamError"
+//  INVALIDPARAMETER_USERNOTEXIST = "InvalidParameter.UserNotExist"
+//  OPERATIONDENIED_SUBUIN = "OperationDenied.SubUin"
+func (c *Client) ListAccessKeys(request *ListAccessKeysRequest) (response *ListAccessKeysResponse, err error) {
+	if request == nil {
+		request = NewListAccessKeysRequest()
+	}
+	response = NewListAccessKeysResponse()
+	err = c.Send(request, response)
+	return
+}
+
+func NewUpdateAccessKeyRequest() (request *UpdateAccessKeyRequest) {
+	request = &UpdateAccessKeyRequest{
+		BaseRequest: &tchttp.BaseRequest{},
+	}
+	request.Init().WithApiInfo("cam", APIVersion, "UpdateAccessKey")
+	return
+}
+
+func NewUpdateAccessKeyResponse() (response *UpdateAccessKeyResponse) {
+	response = &UpdateAccessKeyResponse{
+		BaseResponse: &tchttp.BaseResponse{},
+	}
+	return
+}
+
+// UpdateAccessKey
+// 为CAM用户更新访问密钥状态
+//
+// 可能返回的错误码:
+//  FAILEDOPERATION_ACCESSKEY = "FailedOperation.Accesskey"
+//  INVALIDPARAMETER_PARAMERROR = "InvalidParameter.ParamError"
+//  INVALIDPARAMETER_USERNOTEXIST = "InvalidParameter.UserNotExist"
+//  OPERATIONDENIED_SUBUIN = "OperationDenied.SubUin"
+//  OPERATIONDENIED_UINNOTMATCH = "OperationDenied.UinNotMatch"
+func (c *Client) UpdateAccessKey(request *UpdateAccessKeyRequest) (response *UpdateAccessKeyResponse, err error) {
+	if request == nil {
+		request = NewUpdateAccessKeyRequest()
+	}
+	response = NewUpdateAccessKeyResponse()
+	err = c.Send(request, response)
+	return
+}
+
+func NewGetSecurityLastUsedRequest() (request *GetSecurityLastUsedRequest) {
+	request = &GetSecurityLastUsedRequest{
+		BaseRequest: &tchttp.BaseRequest{},
+	}
+	request.Init().WithApiInfo("cam", APIVersion, "GetSecurityLastUsed")
+	return
+}
+
+func NewGetSecurityLastUsedResponse() (response *GetSecurityLastUsedResponse) {
+	response = &GetSecurityLastUsedResponse{
+		BaseResponse: &tchttp.BaseResponse{},
+	}
+	return
+}
+
+// GetSecurityLastUsed
+// 获取密钥最近使用情况
+//
+// 可能返回的错误码:
+//  FAILEDOPERATION = "FailedOperation"
+//  INVALIDPARAMETER = "InvalidParameter"
+func (c *Client) GetSecurityLastUsed(request *GetSecurityLastUsedRequest) (response *GetSecurityLastUsedResponse, err error) {
+	if request == nil {
+		request = NewGetSecurityLastUsedRequest()
+	}
+	response = NewGetSecurityLastUsedResponse()
+	err = c.Send(request, response)
+	return
+}
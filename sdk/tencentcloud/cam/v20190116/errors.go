@@ -17,9 +17,15 @@ package v20190116
 const (
 	// 此产品的特有错误码
 
+	// 操作失败。
+	FAILEDOPERATION = "FailedOperation"
+
 	// 操作访问密钥错误。
 	FAILEDOPERATION_ACCESSKEY = "FailedOperation.Accesskey"
 
+	// 参数错误。
+	INVALIDPARAMETER = "InvalidParameter"
+
 	// 非法入参。
 	INVALIDPARAMETER_PARAMERROR = "InvalidParameter.ParamError"
 
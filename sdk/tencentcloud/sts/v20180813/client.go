@@ -0,0 +1,164 @@
+// Copyright (c) 2017-2018 THL A29 Limited, a Tencent company. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v20180813
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	tchttp "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/http"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+)
+
+const APIVersion = "2018-08-13"
+
+type Client struct {
+	common.Client
+
+	// Kept for actions that are sent without a signature.
+	region        string
+	clientProfile *profile.ClientProfile
+	httpClient    *http.Client
+}
+
+func NewClient(credential common.CredentialIface, region string, clientProfile *profile.ClientProfile) (client *Client, err error) {
+	client = &Client{
+		region:        region,
+		clientProfile: clientProfile,
+		httpClient: &http.Client{
+			Timeout: time.Duration(clientProfile.HttpProfile.ReqTimeout) * time.Second,
+		},
+	}
+	client.Init(region).
+		WithCredential(credential).
+		WithProfile(clientProfile)
+	return
+}
+
+func (c *Client) WithHttpTransport(transport http.RoundTripper) *Client {
+	c.Client.WithHttpTransport(transport)
+	c.httpClient.Transport = transport
+	return c
+}
+
+func NewGetCallerIdentityRequest() (request *GetCallerIdentityRequest) {
+	request = &GetCallerIdentityRequest{
+		BaseRequest: &tchttp.BaseRequest{},
+	}
+	request.Init().WithApiInfo("sts", APIVersion, "GetCallerIdentity")
+	return
+}
+
+func NewGetCallerIdentityResponse() (response *GetCallerIdentityResponse) {
+	response = &GetCallerIdentityResponse{
+		BaseResponse: &tchttp.BaseResponse{},
+	}
+	return
+}
+
+// GetCallerIdentity
+// 获取当前调用者的身份信息
+//
+// 可能返回的错误码:
+//
+//	AUTHFAILURE_ACCESSKEYILLEGAL = "AuthFailure.AccessKeyIllegal"
+//	INTERNALERROR_SYSTEMERROR = "InternalError.SystemError"
+//	INVALIDPARAMETER_ACCESSKEYNOTSUPPORT = "InvalidParameter.AccessKeyNotSupport"
+func (c *Client) GetCallerIdentity(request *GetCallerIdentityRequest) (response *GetCallerIdentityResponse, err error) {
+	if request == nil {
+		request = NewGetCallerIdentityRequest()
+	}
+	response = NewGetCallerIdentityResponse()
+	err = c.Send(request, response)
+	return
+}
+
+func NewAssumeRoleWithWebIdentityRequest() (request *AssumeRoleWithWebIdentityRequest) {
+	request = &AssumeRoleWithWebIdentityRequest{
+		BaseRequest: &tchttp.BaseRequest{},
+	}
+	request.Init().WithApiInfo("sts", APIVersion, "AssumeRoleWithWebIdentity")
+	return
+}
+
+func NewAssumeRoleWithWebIdentityResponse() (response *AssumeRoleWithWebIdentityResponse) {
+	response = &AssumeRoleWithWebIdentityResponse{
+		BaseResponse: &tchttp.BaseResponse{},
+	}
+	return
+}
+
+// AssumeRoleWithWebIdentity
+// 申请OIDC角色临时密钥
+//
+// The web identity token authenticates the call, so it is sent unsigned.
+//
+// 可能返回的错误码:
+//
+//	INTERNALERROR_GETSEEDTOKENERROR = "InternalError.GetSeedTokenError"
+//	INVALIDPARAMETER_OVERTIMEERROR = "InvalidParameter.OverTimeError"
+//	INVALIDPARAMETER_WEBIDENTITYTOKENERROR = "InvalidParameter.WebIdentityTokenError"
+//	RESOURCENOTFOUND_ROLENOTEXIST = "ResourceNotFound.RoleNotExist"
+func (c *Client) AssumeRoleWithWebIdentity(request *AssumeRoleWithWebIdentityRequest) (response *AssumeRoleWithWebIdentityResponse, err error) {
+	if request == nil {
+		request = NewAssumeRoleWithWebIdentityRequest()
+	}
+	response = NewAssumeRoleWithWebIdentityResponse()
+	err = c.sendUnsigned(request, response)
+	return
+}
+
+func (c *Client) sendUnsigned(request tchttp.Request, response tchttp.Response) error {
+	httpProfile := c.clientProfile.HttpProfile
+	request.SetScheme(httpProfile.Scheme)
+	request.SetRootDomain(httpProfile.RootDomain)
+	domain := httpProfile.Endpoint
+	if domain == "" {
+		domain = request.GetServiceDomain(request.GetService())
+	}
+	request.SetDomain(domain)
+	request.SetHttpMethod("POST")
+	tchttp.CompleteCommonParams(request, c.region)
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	httpRequest, err := http.NewRequest("POST", request.GetScheme()+"://"+domain+"/", strings.NewReader(string(payload)))
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		"Host":           domain,
+		"X-TC-Action":    request.GetAction(),
+		"X-TC-Version":   request.GetVersion(),
+		"X-TC-Timestamp": request.GetParams()["Timestamp"],
+		"X-TC-Region":    c.region,
+		"X-TC-Language":  c.clientProfile.Language,
+		"Content-Type":   "application/json",
+		"Authorization":  "SKIP",
+	}
+	for k, v := range headers {
+		httpRequest.Header[k] = []string{v}
+	}
+	httpResponse, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	return tchttp.ParseFromHttpResponse(httpResponse, response)
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2017-2018 THL A29 Limited, a Tencent company. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v20180813
+
+const (
+	// 此产品的特有错误码
+
+	// 密钥不存在或已失效。
+	AUTHFAILURE_ACCESSKEYILLEGAL = "AuthFailure.AccessKeyIllegal"
+
+	// 系统内部错误。
+	INTERNALERROR_SYSTEMERROR = "InternalError.SystemError"
+
+	// 不支持该类型的密钥。
+	INVALIDPARAMETER_ACCESSKEYNOTSUPPORT = "InvalidParameter.AccessKeyNotSupport"
+
+	// 获取种子token失败。
+	INTERNALERROR_GETSEEDTOKENERROR = "InternalError.GetSeedTokenError"
+
+	// 时间参数不合法。
+	INVALIDPARAMETER_OVERTIMEERROR = "InvalidParameter.OverTimeError"
+
+	// WebIdentityToken 不合法。
+	INVALIDPARAMETER_WEBIDENTITYTOKENERROR = "InvalidParameter.WebIdentityTokenError"
+
+	// 角色不存在。
+	RESOURCENOTFOUND_ROLENOTEXIST = "ResourceNotFound.RoleNotExist"
+)
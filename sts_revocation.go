@@ -0,0 +1,332 @@
+package tencentcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault-plugin-secrets-tencentcloud/clients"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	stsRevocationPath = "sts_revocation/"
+
+	// stsRevocationIdPath holds a random ID naming this mount's deny policies,
+	// so mounts, or replicated clusters, sharing an account don't update each
+	// other's.
+	stsRevocationIdPath = "sts_revocation_id"
+
+	// maxSTSDuration is the longest lifetime Tencent Cloud allows for AssumeRole
+	// credentials. A revoked session can no longer be in use after this long.
+	maxSTSDuration = 43200 * time.Second
+
+	// roleSessionNameConditionKey is the CAM condition key matched against the
+	// session name of temporary credentials.
+	roleSessionNameConditionKey = "qcs:role_session_name"
+
+	revokedSessionsPolicyPrefix = "VaultRevokedSessions-"
+
+	// maxPolicyDocumentLength is the longest policy document CAM accepts.
+	maxPolicyDocumentLength = 6144
+)
+
+// stsRevocation tracks the deny policy attached to a CAM role to cut off the
+// sessions of revoked STS leases before their credentials expire.
+type stsRevocation struct {
+	RoleARN  string               `json:"role_arn"`
+	Account  string               `json:"account"`
+	PolicyId uint64               `json:"policy_id"`
+	Sessions map[string]time.Time `json:"sessions"`
+}
+
+// parseRoleARN splits "qcs::cam::uin/<uin>:roleName/<name>" into its owner uin
+// and role name.
+func parseRoleARN(roleARN string) (uin, roleName string, err error) {
+	idx := strings.Index(roleARN, "uin/")
+	if idx < 0 {
+		return "", "", fmt.Errorf("unable to find uin in role ARN %s", roleARN)
+	}
+	fields := strings.SplitN(roleARN[idx+len("uin/"):], ":", 2)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "roleName/") {
+		return "", "", fmt.Errorf("unable to find role name in role ARN %s", roleARN)
+	}
+	uin = fields[0]
+	roleName = strings.TrimPrefix(fields[1], "roleName/")
+	if uin == "" || roleName == "" {
+		return "", "", fmt.Errorf("malformed role ARN %s", roleARN)
+	}
+	return uin, roleName, nil
+}
+
+// checkRevocationAccount makes sure the role in roleARN belongs to the account
+// of creds, which manage its deny policy.
+func checkRevocationAccount(creds *credConfig, roleARN string) error {
+	uin, _, err := parseRoleARN(roleARN)
+	if err != nil {
+		return err
+	}
+	if creds.AccountUin == "" {
+		return fmt.Errorf("sts_revoke_sessions requires the account uin of the credentials, "+
+			"write them without %s", skipVerification)
+	}
+	if uin != creds.AccountUin {
+		return fmt.Errorf("sts_revoke_sessions requires role_arn %s to be in account %s of the credentials",
+			roleARN, creds.AccountUin)
+	}
+	return nil
+}
+
+// revokeSTSSession denies the given session on the role it was assumed from.
+func (b *backend) revokeSTSSession(ctx context.Context, s logical.Storage,
+	account, roleARN, roleSessionName string) error {
+	uin, camRoleName, err := parseRoleARN(roleARN)
+	if err != nil {
+		return err
+	}
+	creds, err := readAccountConfig(ctx, s, account)
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		return errors.New(credsNotConfigured(account))
+	}
+	if err := checkRevocationAccount(creds, roleARN); err != nil {
+		return fmt.Errorf("unable to revoke STS session: %w", err)
+	}
+	client, err := b.accountCAMClient(ctx, s, account)
+	if err != nil {
+		return fmt.Errorf("unable to revoke STS session: %w", err)
+	}
+
+	b.stsRevocationLock.Lock()
+	defer b.stsRevocationLock.Unlock()
+
+	key := stsRevocationPath + uin + "/" + camRoleName
+	revocation, err := readSTSRevocation(ctx, s, key)
+	if err != nil {
+		return err
+	}
+	if revocation == nil {
+		revocation = &stsRevocation{RoleARN: roleARN, Account: account, Sessions: map[string]time.Time{}}
+	}
+	revocation.Sessions[roleSessionName] = time.Now().UTC().Add(maxSTSDuration)
+	policyName, err := revokedSessionsPolicyName(ctx, s, camRoleName)
+	if err != nil {
+		return err
+	}
+	if err := b.syncRevokedSessionsPolicy(client, policyName, camRoleName, revocation); err != nil {
+		return err
+	}
+	return writeSTSRevocation(ctx, s, key, revocation)
+}
+
+// tidySTSRevocations drops sessions whose credentials have expired, and removes
+// the deny policy from roles that no longer have any revoked sessions.
+func (b *backend) tidySTSRevocations(ctx context.Context, s logical.Storage) error {
+	uins, err := s.List(ctx, stsRevocationPath)
+	if err != nil {
+		return err
+	}
+	if len(uins) == 0 {
+		return nil
+	}
+
+	b.stsRevocationLock.Lock()
+	defer b.stsRevocationLock.Unlock()
+
+	apiErrs := &multierror.Error{}
+	for _, uin := range uins {
+		camRoleNames, err := s.List(ctx, stsRevocationPath+uin)
+		if err != nil {
+			return err
+		}
+		for _, camRoleName := range camRoleNames {
+			key := stsRevocationPath + uin + camRoleName
+			revocation, err := readSTSRevocation(ctx, s, key)
+			if err != nil {
+				return err
+			}
+			if revocation == nil {
+				continue
+			}
+			expired := false
+			for session, expiry := range revocation.Sessions {
+				if time.Now().After(expiry) {
+					delete(revocation.Sessions, session)
+					expired = true
+				}
+			}
+			if !expired {
+				continue
+			}
+			client, err := b.accountCAMClient(ctx, s, revocation.Account)
+			if err != nil {
+				apiErrs = multierror.Append(apiErrs, err)
+				continue
+			}
+			policyName, err := revokedSessionsPolicyName(ctx, s, camRoleName)
+			if err != nil {
+				return err
+			}
+			if err := b.syncRevokedSessionsPolicy(client, policyName, camRoleName, revocation); err != nil {
+				apiErrs = multierror.Append(apiErrs, err)
+				continue
+			}
+			if len(revocation.Sessions) == 0 {
+				err = s.Delete(ctx, key)
+			} else {
+				err = writeSTSRevocation(ctx, s, key, revocation)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return apiErrs.ErrorOrNil()
+}
+
+// accountCAMClient returns the CAM client of the account.
+func (b *backend) accountCAMClient(ctx context.Context, s logical.Storage, account string) (*clients.CAMClient, error) {
+	accountClient, err := b.clientsFor(ctx, s, account)
+	if err != nil {
+		return nil, err
+	}
+	if accountClient == nil {
+		return nil, errors.New(credsNotConfigured(account))
+	}
+	return accountClient.cam, nil
+}
+
+// revokedSessionsPolicyName names the deny policy of the CAM role after the
+// role and the ID of the mount, which is created on first use.
+func revokedSessionsPolicyName(ctx context.Context, s logical.Storage, camRoleName string) (string, error) {
+	entry, err := s.Get(ctx, stsRevocationIdPath)
+	if err != nil {
+		return "", err
+	}
+	var id string
+	if entry != nil {
+		id = string(entry.Value)
+	} else {
+		if id, err = uuid.GenerateUUID(); err != nil {
+			return "", err
+		}
+		id = id[:8]
+		if err := s.Put(ctx, &logical.StorageEntry{Key: stsRevocationIdPath, Value: []byte(id)}); err != nil {
+			return "", err
+		}
+	}
+	return revokedSessionsPolicyPrefix + camRoleName + "-" + id, nil
+}
+
+// syncRevokedSessionsPolicy makes the deny policy on the CAM role match the
+// revoked sessions, creating it as policyName on first use and deleting it
+// once empty.
+func (b *backend) syncRevokedSessionsPolicy(client *clients.CAMClient, policyName, camRoleName string,
+	revocation *stsRevocation) error {
+	if len(revocation.Sessions) == 0 {
+		if revocation.PolicyId == 0 {
+			return nil
+		}
+		// The policy or the role may have been deleted by hand.
+		if err := clients.IgnoreNotFound(client.DetachRolePolicy(&revocation.PolicyId, camRoleName)); err != nil {
+			return err
+		}
+		if err := clients.IgnoreNotFound(client.DeletePolicy([]*uint64{&revocation.PolicyId})); err != nil {
+			return err
+		}
+		revocation.PolicyId = 0
+		return nil
+	}
+
+	policyDoc, err := revokedSessionsPolicyDocument(revocation)
+	if err != nil {
+		return err
+	}
+	if revocation.PolicyId != 0 {
+		err := client.UpdatePolicy(&revocation.PolicyId, policyDoc)
+		if !clients.IsNotFound(err) {
+			return err
+		}
+		// The policy was deleted by hand, create and attach it again.
+		revocation.PolicyId = 0
+	}
+	createPolicyResp, err := client.CreatePolicy(policyName, policyDoc)
+	if err != nil {
+		return err
+	}
+	policyId := *createPolicyResp.Response.PolicyId
+	if err := client.AttachRolePolicy(&policyId, camRoleName); err != nil {
+		// Don't leave the policy behind, or retries would fail on its name.
+		if deleteErr := client.DeletePolicy([]*uint64{&policyId}); deleteErr != nil {
+			return multierror.Append(err, deleteErr)
+		}
+		return err
+	}
+	revocation.PolicyId = policyId
+	return nil
+}
+
+func revokedSessionsPolicyDocument(revocation *stsRevocation) (string, error) {
+	sessions := make([]string, 0, len(revocation.Sessions))
+	for session := range revocation.Sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Strings(sessions)
+	policyDoc, err := jsonutil.EncodeJSON(map[string]interface{}{
+		"version": "2.0",
+		"statement": []map[string]interface{}{
+			{
+				"effect":   "deny",
+				"action":   []string{"*"},
+				"resource": []string{"*"},
+				"condition": map[string]interface{}{
+					"string_equal": map[string]interface{}{
+						roleSessionNameConditionKey: sessions,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(policyDoc) > maxPolicyDocumentLength {
+		return "", fmt.Errorf("%d revoked sessions of %s don't fit in one CAM policy, "+
+			"wait for some of them to expire before revoking more", len(sessions), revocation.RoleARN)
+	}
+	return string(policyDoc), nil
+}
+
+func readSTSRevocation(ctx context.Context, s logical.Storage, key string) (*stsRevocation, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	revocation := &stsRevocation{}
+	if err := entry.DecodeJSON(revocation); err != nil {
+		return nil, err
+	}
+	if revocation.Sessions == nil {
+		revocation.Sessions = map[string]time.Time{}
+	}
+	return revocation, nil
+}
+
+func writeSTSRevocation(ctx context.Context, s logical.Storage, key string, revocation *stsRevocation) error {
+	entry, err := logical.StorageEntryJSON(key, revocation)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
@@ -3,7 +3,11 @@ package tencentcloud
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -45,6 +49,88 @@ func (e *testEnv) AddConfig(t *testing.T) {
 	}
 }
 
+// AddInvalidConfig
+func (e *testEnv) AddInvalidConfig(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"secret_id":  "invalid",
+			"secret_key": e.SecretKey,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected invalid credentials to be rejected")
+	}
+
+	req.Data["skip_verification"] = true
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+
+	req = &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config",
+		Storage:   e.Storage,
+	}
+	if resp, err := e.Backend.HandleRequest(e.Context, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+}
+
+// AddUnauthorizedConfig
+func (e *testEnv) AddUnauthorizedConfig(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"secret_id":          "unauthorized",
+			"secret_key":         e.SecretKey,
+			"verify_permissions": true,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected credentials that may not list CAM policies to be rejected")
+	}
+	if err == nil {
+		err = resp.Error()
+	}
+	if !strings.Contains(err.Error(), "ListPolicies") {
+		t.Fatalf("expected the denied action in %s", err)
+	}
+
+	// Credentials CAM allows pass the same checks as config/check.
+	req.Data["secret_id"] = e.SecretId
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil && len(resp.Warnings) != 0 {
+		t.Fatalf("expected no warnings but received %v", resp.Warnings)
+	}
+
+	req.Data["secret_id"] = "unauthorized"
+	delete(req.Data, "verify_permissions")
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+
+	req = &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config",
+		Storage:   e.Storage,
+	}
+	if resp, err := e.Backend.HandleRequest(e.Context, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+}
+
 // ReadFirstConfig
 func (e *testEnv) ReadFirstConfig(t *testing.T) {
 	req := &logical.Request{
@@ -65,6 +151,9 @@ func (e *testEnv) ReadFirstConfig(t *testing.T) {
 	if resp.Data["secret_key"] != e.SecretKey {
 		t.Fatal("secret_key should not be returned")
 	}
+	if resp.Data["account_uin"] == "" || resp.Data["principal_arn"] == "" {
+		t.Fatal("expected the verified account_uin and principal_arn")
+	}
 }
 
 // UpdateConfig
@@ -245,6 +334,99 @@ func (e *testEnv) ReadPolicyBasedRole(t *testing.T) {
 	}
 }
 
+// PreviewPolicyBasedRole
+func (e *testEnv) PreviewPolicyBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "role/policy-based/preview",
+		Storage:     e.Storage,
+		DisplayName: "token",
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	if resp.Data["role_type"] != "cam" {
+		t.Fatalf("expected role_type of cam but received %s", resp.Data["role_type"])
+	}
+	userName := resp.Data["username"].(string)
+	if !strings.HasPrefix(userName, "token-policy-based-") {
+		t.Fatalf("received unexpected username of %s", userName)
+	}
+	inlinePolicies := resp.Data["inline_policies"].([]map[string]interface{})
+	if len(inlinePolicies) != 2 {
+		t.Fatalf("expected 2 inline policies but received %d", len(inlinePolicies))
+	}
+	for _, inlinePolicy := range inlinePolicies {
+		if !strings.HasPrefix(inlinePolicy["policy_name"].(string), userName+"-") {
+			t.Fatalf("received unexpected policy_name of %s", inlinePolicy["policy_name"])
+		}
+	}
+	remotePolicies := resp.Data["remote_policies"].([]*remotePolicy)
+	if len(remotePolicies) != 3 {
+		t.Fatalf("expected 3 remote policies but received %d", len(remotePolicies))
+	}
+	for _, remotePol := range remotePolicies {
+		if remotePol.PolicyId != 16313162 {
+			t.Fatalf("received unexpected policy_id of %d", remotePol.PolicyId)
+		}
+	}
+	if resp.Data["ttl"] != int64(3600) {
+		t.Fatalf("expected ttl of 3600 but received %v", resp.Data["ttl"])
+	}
+	if resp.Data["max_ttl"] != int64(3600) {
+		t.Fatalf("expected max_ttl of 3600 but received %v", resp.Data["max_ttl"])
+	}
+	if resp.Data["username_source"] != "generated" || len(resp.Warnings) != 0 {
+		t.Fatalf("expected a generated username without warnings but received %v, %v",
+			resp.Data["username_source"], resp.Warnings)
+	}
+}
+
+// PreviewPooledPolicyBasedRole
+func (e *testEnv) PreviewPooledPolicyBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "role/pooled/preview",
+		Storage:     e.Storage,
+		DisplayName: "token",
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp.Data["username_source"] != "pool" {
+		t.Fatalf("expected username_source of pool but received %v", resp.Data["username_source"])
+	}
+	if len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "pool") {
+		t.Fatalf("expected a warning about the pool but received %v", resp.Warnings)
+	}
+}
+
+// PreviewARNBasedRole
+func (e *testEnv) PreviewARNBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "role/role-based/preview",
+		Storage:     e.Storage,
+		DisplayName: "token",
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp.Data["role_type"] != "sts" {
+		t.Fatalf("expected role_type of sts but received %s", resp.Data["role_type"])
+	}
+	// The default 7200 seconds of AssumeRole are capped by the mount max TTL.
+	if resp.Data["ttl"] != int64(3600) || resp.Data["max_ttl"] != int64(3600) {
+		t.Fatalf("expected ttl and max_ttl of 3600 but received %v and %v", resp.Data["ttl"], resp.Data["max_ttl"])
+	}
+}
+
 // AddARNBasedRole
 func (e *testEnv) AddARNBasedRole(t *testing.T) {
 	req := &logical.Request{
@@ -304,6 +486,48 @@ func (e *testEnv) ReadARNBasedRole(t *testing.T) {
 	}
 }
 
+// AddAndReadExternalIdRole
+func (e *testEnv) AddAndReadExternalIdRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/external-id",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"role_arn":             e.RoleARN,
+			"external_id":          "secret-external-id",
+			"allowed_external_ids": "tenant-a,tenant-b",
+			"external_id_required": true,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+
+	data := e.readRoleData(t, "external-id")
+	if data["external_id_set"] != true || data["external_id_required"] != true {
+		t.Fatalf("expected external_id_set and external_id_required but received %v", data)
+	}
+	if allowed := data["allowed_external_ids"].([]string); len(allowed) != 2 || allowed[0] != "tenant-a" {
+		t.Fatalf("unexpected allowed_external_ids %v", allowed)
+	}
+	for key, value := range data {
+		if fmt.Sprint(value) == "secret-external-id" {
+			t.Fatalf("expected the external ID to never be returned but found it under %s", key)
+		}
+	}
+
+	req = &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "role/external-id",
+		Storage:   e.Storage,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+}
+
 // UpdateARNBasedRole
 func (e *testEnv) UpdateARNBasedRole(t *testing.T) {
 	req := &logical.Request{
@@ -363,11 +587,11 @@ func (e *testEnv) ReadUpdatedRole(t *testing.T) {
 	}
 }
 
-// ListTwoRoles
-func (e *testEnv) ListTwoRoles(t *testing.T) {
+// ListARNBasedRoleVersions
+func (e *testEnv) ListARNBasedRoleVersions(t *testing.T) {
 	req := &logical.Request{
 		Operation: logical.ListOperation,
-		Path:      "role",
+		Path:      "role/role-based/versions",
 		Storage:   e.Storage,
 	}
 	resp, err := e.Backend.HandleRequest(e.Context, req)
@@ -381,35 +605,16 @@ func (e *testEnv) ListTwoRoles(t *testing.T) {
 	if len(keys) != 2 {
 		t.Fatalf("expected 2 keys but received %d", len(keys))
 	}
-	if keys[0] != "policy-based" {
-		t.Fatalf("expectied policy-based role name but received %s", keys[0])
-	}
-	if keys[1] != "role-based" {
-		t.Fatalf("expected role-based role name but received %s", keys[1])
-	}
-}
-
-// DeleteARNBasedRole
-func (e *testEnv) DeleteARNBasedRole(t *testing.T) {
-	req := &logical.Request{
-		Operation: logical.DeleteOperation,
-		Path:      "role/role-based",
-		Storage:   e.Storage,
-	}
-	resp, err := e.Backend.HandleRequest(e.Context, req)
-	if err != nil || (resp != nil && resp.IsError()) {
-		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
-	}
-	if resp != nil {
-		t.Fatal("expected nil response to represent a 204")
+	if keys[0] != "1" || keys[1] != "2" {
+		t.Fatalf("expected versions 1 and 2 but received %v", keys)
 	}
 }
 
-// ListOneRole
-func (e *testEnv) ListOneRole(t *testing.T) {
+// ReadFirstARNBasedRoleVersion
+func (e *testEnv) ReadFirstARNBasedRoleVersion(t *testing.T) {
 	req := &logical.Request{
-		Operation: logical.ListOperation,
-		Path:      "role",
+		Operation: logical.ReadOperation,
+		Path:      "role/role-based/versions/1",
 		Storage:   e.Storage,
 	}
 	resp, err := e.Backend.HandleRequest(e.Context, req)
@@ -419,21 +624,23 @@ func (e *testEnv) ListOneRole(t *testing.T) {
 	if resp == nil {
 		t.Fatal("expected a response")
 	}
-	keys := resp.Data["keys"].([]string)
-	if len(keys) != 1 {
-		t.Fatalf("expected 2 keys but received %d", len(keys))
+	if resp.Data["role_arn"] != e.RoleARN {
+		t.Fatalf("received unexpected role_arn of %s", resp.Data["role_arn"])
 	}
-	if keys[0] != "policy-based" {
-		t.Fatalf("expectied policy-based role name but received %s", keys[0])
+	if resp.Data["version"] != 1 {
+		t.Fatalf("expected version 1 but received %v", resp.Data["version"])
 	}
 }
 
-// ReadPolicyBasedCreds
-func (e *testEnv) ReadPolicyBasedCreds(t *testing.T) {
+// ExportAndImportRoles
+func (e *testEnv) ExportAndImportRoles(t *testing.T) {
 	req := &logical.Request{
 		Operation: logical.ReadOperation,
-		Path:      "creds/policy-based",
+		Path:      "roles/export",
 		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"names": "policy-based",
+		},
 	}
 	resp, err := e.Backend.HandleRequest(e.Context, req)
 	if err != nil || (resp != nil && resp.IsError()) {
@@ -442,108 +649,486 @@ func (e *testEnv) ReadPolicyBasedCreds(t *testing.T) {
 	if resp == nil {
 		t.Fatal("expected a response")
 	}
-
-	if resp.Data["secret_id"] == "" {
-		t.Fatal("failed to receive secret_id")
-	}
-	if resp.Data["secret_key"] == "" {
-		t.Fatal("failed to receive secret_key")
+	exported := resp.Data["roles"].(map[string]interface{})
+	if len(exported) != 1 {
+		t.Fatalf("expected 1 exported role but received %d", len(exported))
 	}
-	e.MostRecentSecret = resp.Secret
-}
 
-// RenewPolicyBasedCreds
-func (e *testEnv) RenewPolicyBasedCreds(t *testing.T) {
-	req := &logical.Request{
-		Operation: logical.RenewOperation,
+	req = &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/import",
 		Storage:   e.Storage,
-		Secret:    e.MostRecentSecret,
 		Data: map[string]interface{}{
-			"lease_id": "foo",
+			"roles": exported,
 		},
 	}
-	resp, err := e.Backend.HandleRequest(e.Context, req)
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err == nil {
+		t.Fatalf("expected an error importing an existing role but received %#v", resp)
+	}
+
+	req.Data = map[string]interface{}{
+		"roles": map[string]interface{}{
+			"elsewhere": map[string]interface{}{
+				"remote_policies": []interface{}{
+					map[string]interface{}{"policy_name": "QcloudAFCFullAccess", "scope": "All"},
+				},
+				"account": "missing",
+			},
+		},
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err == nil {
+		t.Fatalf("expected an error importing a role of a missing account but received %#v", resp)
+	}
+
+	e.exportAndImportFixedExternalId(t)
+
+	// roles must be an object, not JSON or HCL in a string.
+	req.Data = map[string]interface{}{
+		"roles": `{"elsewhere": {"remote_policies": [{"policy_name": "QcloudAFCFullAccess", "scope": "All"}]}}`,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected an error importing roles as a string but received %#v", resp)
+	}
+
+	roles := map[string]interface{}{
+		"policy-based": exported["policy-based"],
+		"copied":       exported["policy-based"],
+	}
+	req.Data = map[string]interface{}{
+		"roles":         roles,
+		"conflict_mode": "skip",
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
 	if err != nil || (resp != nil && resp.IsError()) {
 		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
 	}
-	if resp == nil {
-		t.Fatal("expected a response")
+	imported := resp.Data["imported"].([]string)
+	if len(imported) != 1 || imported[0] != "copied" {
+		t.Fatalf("expected copied to be imported but received %v", imported)
 	}
-	if resp.Secret != e.MostRecentSecret {
-		t.Fatalf("expected %+v but got %+v", e.MostRecentSecret, resp.Secret)
+	skipped := resp.Data["skipped"].([]string)
+	if len(skipped) != 1 || skipped[0] != "policy-based" {
+		t.Fatalf("expected policy-based to be skipped but received %v", skipped)
+	}
+
+	for _, roleName := range []string{"copied", "fixed-external-id"} {
+		req = &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "role/" + roleName,
+			Storage:   e.Storage,
+		}
+		if resp, err := e.Backend.HandleRequest(e.Context, req); err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+		}
 	}
 }
 
-// RevokePolicyBasedCreds
-func (e *testEnv) RevokePolicyBasedCreds(t *testing.T) {
+// slowListStorage lists slowly, so concurrent requests interleave between
+// reading the latest role version and writing the next.
+type slowListStorage struct {
+	logical.Storage
+}
+
+func (s *slowListStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.Storage.List(ctx, prefix)
+	time.Sleep(10 * time.Millisecond)
+	return keys, err
+}
+
+// WriteRoleConcurrently
+func (e *testEnv) WriteRoleConcurrently(t *testing.T) {
+	storage := &slowListStorage{e.Storage}
+	remotePolicies := []interface{}{
+		map[string]interface{}{"policy_name": "QcloudAFCFullAccess", "scope": "All"},
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, roleVersionsKept)
+	for i := 0; i < roleVersionsKept; i++ {
+		req := &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "role/concurrent",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"remote_policies": remotePolicies,
+				"ttl":             60 + i,
+			},
+		}
+		if i%2 == 1 {
+			req = &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "roles/import",
+				Storage:   storage,
+				Data: map[string]interface{}{
+					"roles": map[string]interface{}{
+						"concurrent": map[string]interface{}{"remote_policies": remotePolicies},
+					},
+					"conflict_mode": "overwrite",
+				},
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := e.Backend.HandleRequest(e.Context, req)
+			if err == nil && resp != nil && resp.IsError() {
+				err = resp.Error()
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := listRoleVersions(e.Context, e.Storage, "concurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != roleVersionsKept {
+		t.Fatalf("expected %d versions but received %v", roleVersionsKept, versions)
+	}
+	role, err := readRole(e.Context, e.Storage, "concurrent")
+	if err != nil || role == nil || role.Version != roleVersionsKept {
+		t.Fatalf("expected version %d but received %+v, %v", roleVersionsKept, role, err)
+	}
+}
+
+// exportAndImportFixedExternalId exports a role with a fixed external ID,
+// which only an import keeping or setting an external ID accepts.
+func (e *testEnv) exportAndImportFixedExternalId(t *testing.T) {
 	req := &logical.Request{
-		Operation: logical.RevokeOperation,
+		Operation: logical.CreateOperation,
+		Path:      "role/fixed-external-id",
 		Storage:   e.Storage,
-		Secret:    e.MostRecentSecret,
 		Data: map[string]interface{}{
-			"lease_id": "foo",
+			"role_arn":    e.RoleARN,
+			"external_id": "fixed",
 		},
 	}
-	resp, err := e.Backend.HandleRequest(e.Context, req)
-	if err != nil || (resp != nil && resp.IsError()) {
+	if resp, err := e.Backend.HandleRequest(e.Context, req); err != nil || (resp != nil && resp.IsError()) {
 		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
 	}
-	if resp != nil {
-		t.Fatal("expected nil response to represent a 204")
-	}
-}
-
-// ReadARNBasedCreds
-func (e *testEnv) ReadARNBasedCreds(t *testing.T) {
-	req := &logical.Request{
+	req = &logical.Request{
 		Operation: logical.ReadOperation,
-		Path:      "creds/role-based",
+		Path:      "roles/export",
 		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"names": "fixed-external-id",
+		},
 	}
 	resp, err := e.Backend.HandleRequest(e.Context, req)
-	if err != nil || (resp != nil && resp.IsError()) {
+	if err != nil || resp == nil || resp.IsError() {
 		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
 	}
-	if resp == nil {
-		t.Fatal("expected a response")
+	exported := resp.Data["roles"].(map[string]interface{})["fixed-external-id"].(map[string]interface{})
+	if _, ok := exported["external_id"]; ok || exported["external_id_set"] != true {
+		t.Fatalf("expected only external_id_set to be exported but received %v", exported)
 	}
 
-	if resp.Data["secret_id"] == "" {
-		t.Fatal("received blank secret_id")
+	importRoles := func(roles map[string]interface{}) (*logical.Response, error) {
+		return e.Backend.HandleRequest(e.Context, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/import",
+			Storage:   e.Storage,
+			Data: map[string]interface{}{
+				"roles":         roles,
+				"conflict_mode": "overwrite",
+			},
+		})
 	}
-	if resp.Data["secret_key"] == "" {
-		t.Fatal("received blank secret_key")
+	// A new role would lose the external ID.
+	if resp, err := importRoles(map[string]interface{}{"fixed-copy": exported}); err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected an error importing a role without its external_id but received %#v", resp)
 	}
-	if fmt.Sprintf("%s", resp.Data["expiration"]) == "" {
-		t.Fatal("received blank expiration")
+	// Overwriting the role keeps its external ID.
+	if resp, err := importRoles(map[string]interface{}{"fixed-external-id": exported}); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
 	}
-	if resp.Data["token"] == "" {
-		t.Fatal("received blank token")
+	role, err := readRole(e.Context, e.Storage, "fixed-external-id")
+	if err != nil || role == nil || role.ExternalId != "fixed" {
+		t.Fatalf("expected the external_id to be kept but received %+v, %v", role, err)
 	}
-	e.MostRecentSecret = resp.Secret
 }
 
-// RenewARNBasedCreds
-func (e *testEnv) RenewARNBasedCreds(t *testing.T) {
+// AddAndReadStructuredPolicyRole
+func (e *testEnv) AddAndReadStructuredPolicyRole(t *testing.T) {
 	req := &logical.Request{
-		Operation: logical.RenewOperation,
+		Operation: logical.CreateOperation,
+		Path:      "role/structured",
 		Storage:   e.Storage,
-		Secret:    e.MostRecentSecret,
 		Data: map[string]interface{}{
-			"lease_id": "foo",
+			"remote_policies": []interface{}{
+				map[string]interface{}{
+					"policy_name": "Qcloud:Colon",
+					"scope":       "All",
+				},
+			},
+			"inline_policies": []interface{}{
+				map[string]interface{}{
+					"version": "2.0",
+					"statement": []interface{}{
+						map[string]interface{}{
+							"action":   []interface{}{"af:*"},
+							"resource": "*",
+							"effect":   "allow",
+						},
+					},
+				},
+			},
 		},
 	}
 	resp, err := e.Backend.HandleRequest(e.Context, req)
 	if err != nil || (resp != nil && resp.IsError()) {
 		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
 	}
-	if resp != nil {
-		t.Fatal("expected nil response to represent a 204")
-	}
+
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "role/structured",
+		Storage:   e.Storage,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	remotePolicies := resp.Data["remote_policies"].([]*remotePolicy)
+	if len(remotePolicies) != 1 || remotePolicies[0].PolicyName != "Qcloud:Colon" {
+		t.Fatalf("received unexpected remote policies %+v", remotePolicies)
+	}
+	inlinePolicies := resp.Data["inline_policies"].([]*inlinePolicy)
+	if len(inlinePolicies) != 1 || inlinePolicies[0].PolicyDocument["version"] != "2.0" {
+		t.Fatalf("received unexpected inline policies %+v", inlinePolicies)
+	}
+	hash := inlinePolicies[0].UUID
+
+	// Writing back what was read must be accepted and keep the same policy.
+	req = &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/structured",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"inline_policies": []interface{}{
+				map[string]interface{}{
+					"hash":            hash,
+					"policy_document": inlinePolicies[0].PolicyDocument,
+				},
+			},
+		},
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	role, err := readRole(e.Context, e.Storage, "structured")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(role.InlinePolicies) != 1 || role.InlinePolicies[0].UUID != hash {
+		t.Fatalf("expected inline policy %s to be kept but received %+v", hash, role.InlinePolicies)
+	}
+
+	req = &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "role/structured",
+		Storage:   e.Storage,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
 }
 
-// RevokeARNBasedCreds
-func (e *testEnv) RevokeARNBasedCreds(t *testing.T) {
+// ListTwoRoles
+func (e *testEnv) ListTwoRoles(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "role",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	keys := resp.Data["keys"].([]string)
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 keys but received %d", len(keys))
+	}
+	if keys[0] != "policy-based" {
+		t.Fatalf("expectied policy-based role name but received %s", keys[0])
+	}
+	if keys[1] != "role-based" {
+		t.Fatalf("expected role-based role name but received %s", keys[1])
+	}
+}
+
+// ListRolesDetailed
+func (e *testEnv) ListRolesDetailed(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "role",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"detailed": true,
+			"type":     "sts",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	keys := resp.Data["keys"].([]string)
+	if len(keys) != 1 || keys[0] != "role-based" {
+		t.Fatalf("expected only role-based but received %v", keys)
+	}
+	info := resp.Data["key_info"].(map[string]interface{})["role-based"].(map[string]interface{})
+	if info["role_type"] != "sts" {
+		t.Fatalf("expected role_type of sts but received %s", info["role_type"])
+	}
+
+	req.Data = map[string]interface{}{
+		"after": "policy-based",
+		"limit": 1,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	keys = resp.Data["keys"].([]string)
+	if len(keys) != 1 || keys[0] != "role-based" {
+		t.Fatalf("expected only role-based but received %v", keys)
+	}
+}
+
+// DeleteARNBasedRole
+func (e *testEnv) DeleteARNBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "role/role-based",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil {
+		t.Fatal("expected nil response to represent a 204")
+	}
+}
+
+// ListOneRole
+func (e *testEnv) ListOneRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "role",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	keys := resp.Data["keys"].([]string)
+	if len(keys) != 1 {
+		t.Fatalf("expected 2 keys but received %d", len(keys))
+	}
+	if keys[0] != "policy-based" {
+		t.Fatalf("expectied policy-based role name but received %s", keys[0])
+	}
+}
+
+// ReadPolicyBasedCreds
+func (e *testEnv) ReadPolicyBasedCreds(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/policy-based",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+
+	if resp.Data["secret_id"] == "" {
+		t.Fatal("failed to receive secret_id")
+	}
+	if resp.Data["secret_key"] == "" {
+		t.Fatal("failed to receive secret_key")
+	}
+	e.MostRecentSecret = resp.Secret
+}
+
+// ReadPolicyBasedCredsWithTTL
+func (e *testEnv) ReadPolicyBasedCredsWithTTL(t *testing.T) {
+	for requested, expected := range map[int]time.Duration{
+		300:  5 * time.Minute,
+		7200: time.Hour,
+	} {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/policy-based",
+			Storage:   e.Storage,
+			Data: map[string]interface{}{
+				"ttl": requested,
+			},
+		}
+		resp, err := e.Backend.HandleRequest(e.Context, req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+		}
+		if resp == nil {
+			t.Fatal("expected a response")
+		}
+		if resp.Secret.TTL != expected {
+			t.Fatalf("expected ttl of %s but received %s", expected, resp.Secret.TTL)
+		}
+	}
+}
+
+// RenewPolicyBasedCreds
+func (e *testEnv) RenewPolicyBasedCreds(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   e.Storage,
+		Secret:    e.MostRecentSecret,
+		Data: map[string]interface{}{
+			"lease_id": "foo",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	if resp.Secret != e.MostRecentSecret {
+		t.Fatalf("expected %+v but got %+v", e.MostRecentSecret, resp.Secret)
+	}
+}
+
+// RevokePolicyBasedCreds
+func (e *testEnv) RevokePolicyBasedCreds(t *testing.T) {
 	req := &logical.Request{
 		Operation: logical.RevokeOperation,
 		Storage:   e.Storage,
@@ -561,29 +1146,1142 @@ func (e *testEnv) RevokeARNBasedCreds(t *testing.T) {
 	}
 }
 
-const policyDocument = ` [
-    {
-        "version":"2.0",
-        "statement":[
-            {
-                "action":[
-                    "af:*"
-                ],
-                "resource":"*",
-                "effect":"allow"
-            }
-        ]
-    },
-    {
-        "version":"2.0",
-        "statement":[
-            {
-                "action":[
-                    "afc:*"
-                ],
-                "resource":"*",
-                "effect":"allow"
-            }
-        ]
-    }
-]`
+// RevokeDeletedPolicyBasedCreds revokes creds whose user was already deleted
+// outside of Vault, which counts as revoked.
+func (e *testEnv) RevokeDeletedPolicyBasedCreds(t *testing.T) {
+	internalData := make(map[string]interface{}, len(e.MostRecentSecret.InternalData))
+	for k, v := range e.MostRecentSecret.InternalData {
+		internalData[k] = v
+	}
+	internalData["username"] = "deleted-by-hand"
+	internalData["uin"] = "100000000404"
+	req := &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   e.Storage,
+		Secret: &logical.Secret{
+			InternalData: internalData,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil {
+		t.Fatal("expected nil response to represent a 204")
+	}
+}
+
+// RevokeDeletedAccessKeyPolicyBasedCreds revokes creds whose access key alone
+// was already deleted outside of Vault.
+func (e *testEnv) RevokeDeletedAccessKeyPolicyBasedCreds(t *testing.T) {
+	internalData := make(map[string]interface{}, len(e.MostRecentSecret.InternalData))
+	for k, v := range e.MostRecentSecret.InternalData {
+		internalData[k] = v
+	}
+	internalData["secret_id"] = "AKIDdeletedbyhand"
+	req := &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   e.Storage,
+		Secret: &logical.Secret{
+			InternalData: internalData,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil {
+		t.Fatal("expected nil response to represent a 204")
+	}
+}
+
+// ReadARNBasedCreds
+func (e *testEnv) ReadARNBasedCreds(t *testing.T) {
+	req := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        "creds/role-based",
+		Storage:     e.Storage,
+		EntityID:    "entity-role-based",
+		DisplayName: "token",
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+
+	if resp.Data["secret_id"] == "" {
+		t.Fatal("received blank secret_id")
+	}
+	if resp.Data["secret_key"] == "" {
+		t.Fatal("received blank secret_key")
+	}
+	if fmt.Sprintf("%s", resp.Data["expiration"]) == "" {
+		t.Fatal("received blank expiration")
+	}
+	if resp.Data["token"] == "" {
+		t.Fatal("received blank token")
+	}
+	e.MostRecentSecret = resp.Secret
+}
+
+// RenewARNBasedCreds
+func (e *testEnv) RenewARNBasedCreds(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   e.Storage,
+		Secret:    e.MostRecentSecret,
+		Data: map[string]interface{}{
+			"lease_id": "foo",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil {
+		t.Fatal("expected nil response to represent a 204")
+	}
+}
+
+// AddRenewableARNBasedRole
+func (e *testEnv) AddRenewableARNBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/role-based",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"role_arn":      e.RoleARN,
+			"sts_renewable": true,
+			"max_ttl":       3600,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if data := e.readRoleData(t, "role-based"); data["sts_renewable"] != true {
+		t.Fatalf("expected sts_renewable to be read back but received %v", data["sts_renewable"])
+	}
+}
+
+// readRoleData returns what reading the role returns, checking that reading
+// its latest version returns the same settings.
+func (e *testEnv) readRoleData(t *testing.T, roleName string) map[string]interface{} {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "role/" + roleName,
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	req.Path = fmt.Sprintf("role/%s/versions/%d", roleName, resp.Data["version"])
+	versionResp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || versionResp == nil || versionResp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr:%v", versionResp, err)
+	}
+	for key, value := range resp.Data {
+		if !reflect.DeepEqual(versionResp.Data[key], value) {
+			t.Fatalf("expected version %v of %s to return %s of %v but received %v",
+				resp.Data["version"], roleName, key, value, versionResp.Data[key])
+		}
+	}
+	return resp.Data
+}
+
+// RenewRenewableARNBasedCreds
+func (e *testEnv) RenewRenewableARNBasedCreds(t *testing.T) {
+	if !e.MostRecentSecret.Renewable {
+		t.Fatal("expected a renewable secret")
+	}
+	e.MostRecentSecret.IssueTime = time.Now()
+	req := &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   e.Storage,
+		Secret:    e.MostRecentSecret,
+		Data: map[string]interface{}{
+			"lease_id": "foo",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	if resp.Data["token"] == "" || resp.Data["secret_id"] == "" {
+		t.Fatalf("expected fresh credentials but received %#v", resp.Data)
+	}
+	sessionName := resp.Secret.InternalData["role_session_name"].(string)
+	if !strings.Contains(sessionName, "role-based") {
+		t.Fatalf("received unexpected role_session_name of %s", sessionName)
+	}
+
+	// Renewals don't know who asked for the lease, so the new credentials keep
+	// the requester of those they replace.
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "issued/" + resp.Data["secret_id"].(string),
+		Storage:   e.Storage,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp.Data["entity_id"] != "entity-role-based" || resp.Data["display_name"] != "token" ||
+		resp.Data["lease_path"] != "creds/role-based" {
+		t.Fatalf("expected the requester of the renewed lease but received %v", resp.Data)
+	}
+}
+
+// AddRevokingARNBasedRole
+func (e *testEnv) AddRevokingARNBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/role-based",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"role_arn":            e.RoleARN,
+			"sts_revoke_sessions": true,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if data := e.readRoleData(t, "role-based"); data["sts_revoke_sessions"] != true {
+		t.Fatalf("expected sts_revoke_sessions to be true but received %v", data["sts_revoke_sessions"])
+	}
+}
+
+// AddCrossAccountRevokingARNBasedRole
+func (e *testEnv) AddCrossAccountRevokingARNBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/cross-account",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"role_arn":            "qcs::cam::uin/100021543999:roleName/firingrole001",
+			"sts_revoke_sessions": true,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected a role in another account to be rejected")
+	}
+}
+
+// RecreateRevokedSessionsPolicy
+func (e *testEnv) RecreateRevokedSessionsPolicy(t *testing.T) {
+	key := stsRevocationPath + "100021543443/firingrole001"
+	revocation, err := readSTSRevocation(e.Context, e.Storage, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revocation == nil {
+		t.Fatal("expected a revoked session")
+	}
+	revocation.PolicyId = 404
+	if err := writeSTSRevocation(e.Context, e.Storage, key, revocation); err != nil {
+		t.Fatal(err)
+	}
+	// Revoking the same session again updates the policy, which was deleted.
+	for session := range revocation.Sessions {
+		if err := e.Backend.(*backend).revokeSTSSession(e.Context, e.Storage, "", revocation.RoleARN, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revocation, err = readSTSRevocation(e.Context, e.Storage, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revocation == nil || revocation.PolicyId != 17698703 || len(revocation.Sessions) != 1 {
+		t.Fatalf("expected the policy to be created again as 17698703 but received %+v", revocation)
+	}
+}
+
+// TidyRevokedSessions
+func (e *testEnv) TidyRevokedSessions(t *testing.T) {
+	key := stsRevocationPath + "100021543443/firingrole001"
+	revocation, err := readSTSRevocation(e.Context, e.Storage, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revocation == nil || revocation.PolicyId != 17698703 || len(revocation.Sessions) != 1 {
+		t.Fatalf("expected one revoked session under policy 17698703 but received %+v", revocation)
+	}
+	for session := range revocation.Sessions {
+		revocation.Sessions[session] = time.Now().Add(-time.Minute)
+	}
+	if err := writeSTSRevocation(e.Context, e.Storage, key, revocation); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Backend.(*backend).tidySTSRevocations(e.Context, e.Storage); err != nil {
+		t.Fatal(err)
+	}
+	revocation, err = readSTSRevocation(e.Context, e.Storage, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revocation != nil {
+		t.Fatalf("expected revocation to be removed but received %+v", revocation)
+	}
+}
+
+// RevokeARNBasedCreds
+func (e *testEnv) RevokeARNBasedCreds(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   e.Storage,
+		Secret:    e.MostRecentSecret,
+		Data: map[string]interface{}{
+			"lease_id": "foo",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil {
+		t.Fatal("expected nil response to represent a 204")
+	}
+}
+
+const policyDocument = ` [
+    {
+        "version":"2.0",
+        "statement":[
+            {
+                "action":[
+                    "af:*"
+                ],
+                "resource":"*",
+                "effect":"allow"
+            }
+        ]
+    },
+    {
+        "version":"2.0",
+        "statement":[
+            {
+                "action":[
+                    "afc:*"
+                ],
+                "resource":"*",
+                "effect":"allow"
+            }
+        ]
+    }
+]`
+
+// AddAccount
+func (e *testEnv) AddAccount(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config/account/prod",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"secret_id":  e.SecretId,
+			"secret_key": e.SecretKey,
+			"region":     "ap-guangzhou",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil {
+		t.Fatal("expected nil response to represent a 204")
+	}
+}
+
+// ReadAccount
+func (e *testEnv) ReadAccount(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/account/prod",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	if resp.Data["secret_id"] != e.SecretId {
+		t.Fatal("expected secret_id of " + e.SecretId)
+	}
+	if _, ok := resp.Data["secret_key"]; ok {
+		t.Fatal("secret_key should not be returned")
+	}
+	if resp.Data["region"] != "ap-guangzhou" {
+		t.Fatalf("expected region of ap-guangzhou but received %v", resp.Data["region"])
+	}
+
+	req = &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "config/account/",
+		Storage:   e.Storage,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	keys := resp.Data["keys"].([]string)
+	if len(keys) != 1 || keys[0] != "prod" {
+		t.Fatalf("expected the prod account but received %v", keys)
+	}
+}
+
+// AddAccountBasedRole
+func (e *testEnv) AddAccountBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/account-based",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"remote_policies": []string{
+				"policy_name:QcloudAFCFullAccess,scope:All",
+			},
+			"account": "staging",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected an error for an account that isn't configured")
+	}
+
+	req.Data["account"] = "Prod"
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil {
+		t.Fatal("expected nil response to represent a 204")
+	}
+	if data := e.readRoleData(t, "account-based"); data["account"] != "prod" {
+		t.Fatalf("expected account of prod but received %v", data["account"])
+	}
+}
+
+// ReadAccountBasedCreds
+func (e *testEnv) ReadAccountBasedCreds(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/account-based",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+	if resp.Secret.InternalData["account"] != "prod" {
+		t.Fatalf("expected the lease to record the prod account but received %v",
+			resp.Secret.InternalData["account"])
+	}
+	e.MostRecentSecret = resp.Secret
+}
+
+// DeleteAccountInUse
+func (e *testEnv) DeleteAccountInUse(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config/account/prod",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected an error deleting an account used by a role")
+	}
+}
+
+// AddChainedARNBasedRole
+func (e *testEnv) AddChainedARNBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/role-based",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"role_arn":            e.RoleARN,
+			"source_role_arn":     "qcs::cam::uin/100021543440:roleName/hub",
+			"sts_revoke_sessions": true,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected an error revoking sessions of a chained role")
+	}
+
+	delete(req.Data, "sts_revoke_sessions")
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil {
+		t.Fatal("expected nil response to represent a 204")
+	}
+}
+
+// AddWebIdentityConfig
+func (e *testEnv) AddWebIdentityConfig(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"role_arn":                "qcs::cam::uin/100021543443:roleName/vault",
+			"identity_provider_id":    "vault",
+			"identity_token_audience": "tencentcloud",
+			"secret_id":               "foo",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected an error combining role_arn and secret_id")
+	}
+
+	delete(req.Data, "secret_id")
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config",
+		Storage:   e.Storage,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp.Data["identity_token_audience"] != "tencentcloud" {
+		t.Fatalf("expected identity_token_audience of tencentcloud but received %v",
+			resp.Data["identity_token_audience"])
+	}
+}
+
+// AddUnsupportedWebIdentityConfig
+func (e *testEnv) AddUnsupportedWebIdentityConfig(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"role_arn":                "qcs::cam::uin/100021543443:roleName/vault",
+			"identity_provider_id":    "vault",
+			"identity_token_audience": "tencentcloud",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected an error where plugin identity tokens are not supported")
+	}
+}
+
+// AddCVMRoleConfig
+func (e *testEnv) AddCVMRoleConfig(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"credential_source": "cvm_role",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected an error omitting cvm_role_name")
+	}
+
+	req.Data["cvm_role_name"] = "vault-cvm"
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+}
+
+// ReadCVMRoleConfig
+func (e *testEnv) ReadCVMRoleConfig(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if len(resp.Warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", resp.Warnings)
+	}
+	if resp.Data["active_role"] != "vault-cvm" {
+		t.Fatalf("expected active_role of vault-cvm but received %v", resp.Data["active_role"])
+	}
+	if resp.Data["credential_expiration"] != "2100-01-01T00:00:00Z" {
+		t.Fatalf("expected credential_expiration of 2100-01-01T00:00:00Z but received %v",
+			resp.Data["credential_expiration"])
+	}
+}
+
+// CheckConfig
+func (e *testEnv) CheckConfig(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/check",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if len(resp.Warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", resp.Warnings)
+	}
+	if resp.Data["permitted"] != true {
+		t.Fatalf("expected all checks to pass but received %v", resp.Data["checks"])
+	}
+	checks := resp.Data["checks"].(map[string]map[string]interface{})
+	for _, action := range []string{"AddUser", "CreatePolicy", "AttachUserPolicy", "CreateAccessKey",
+		"DeleteAccessKey", "DetachUserPolicy", "DeletePolicy", "DeleteUser", "ListPolicies"} {
+		if checks[action]["status"] != checkStatusOK {
+			t.Fatalf("expected %s to be ok but received %v", action, checks[action])
+		}
+	}
+	if checks["AssumeRole"]["status"] != checkStatusSkipped {
+		t.Fatalf("expected AssumeRole to be skipped but received %v", checks["AssumeRole"])
+	}
+
+	// Member roles may only be assumed by a hub role, so the mock denies it.
+	req.Data = map[string]interface{}{
+		"role_arn": "qcs::cam::uin/100000000002:roleName/member",
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp.Data["permitted"] != false {
+		t.Fatal("expected the check to report AssumeRole as not permitted")
+	}
+	checks = resp.Data["checks"].(map[string]map[string]interface{})
+	if checks["AssumeRole"]["status"] != checkStatusFailed || checks["AssumeRole"]["error"] == "" {
+		t.Fatalf("expected AssumeRole to fail but received %v", checks["AssumeRole"])
+	}
+}
+
+// AddPropagatingPolicyBasedRole
+func (e *testEnv) AddPropagatingPolicyBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/policy-based",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"inline_policies":      policyDocument,
+			"wait_for_propagation": true,
+			"propagation_timeout":  10,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+
+	data := e.readRoleData(t, "policy-based")
+	if data["wait_for_propagation"] != true || fmt.Sprintf("%d", data["propagation_timeout"]) != "10" {
+		t.Fatalf("expected wait_for_propagation and a propagation_timeout of 10 but received %v and %v",
+			data["wait_for_propagation"], data["propagation_timeout"])
+	}
+
+	req.Path = "role/arn-based"
+	req.Data = map[string]interface{}{
+		"role_arn":             e.RoleARN,
+		"wait_for_propagation": true,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected wait_for_propagation to be rejected for an arn-based role")
+	}
+}
+
+// ReadPropagatedCreds
+func (e *testEnv) ReadPropagatedCreds(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/policy-based",
+		Storage:   e.Storage,
+	}
+	start := time.Now()
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if len(resp.Warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", resp.Warnings)
+	}
+	// The first attempt is rejected, so at least one backoff was waited out.
+	if time.Since(start) < propagationInitialBackoff {
+		t.Fatal("expected creds to be returned only once the access key was accepted")
+	}
+	if resp.Data["secret_id"] != "ABBD8GFED7sSr33rSq9KK7h5ISSEoQrFXkmb" {
+		t.Fatalf("received unexpected secret_id of %v", resp.Data["secret_id"])
+	}
+}
+
+// AddPooledPolicyBasedRole
+func (e *testEnv) AddPooledPolicyBasedRole(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/pooled",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"remote_policies": []string{
+				"policy_name:QcloudAFCFullAccess,scope:All",
+			},
+			"inline_policies": policyDocument,
+			"pool_size":       2,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if data := e.readRoleData(t, "pooled"); data["pool_size"] != 2 {
+		t.Fatalf("expected pool_size of 2 but received %v", data["pool_size"])
+	}
+}
+
+// SkipPeriodicFunc
+func (e *testEnv) SkipPeriodicFunc(t *testing.T) {
+	req := &logical.Request{Storage: e.Storage}
+	if err := e.Backend.(*backend).periodicFunc(e.Context, req); err != nil {
+		t.Fatal(err)
+	}
+	if members := e.poolMembers(t); len(members) != 0 {
+		t.Fatalf("expected the pool to be left empty but received %v", members)
+	}
+}
+
+// FillPool
+func (e *testEnv) FillPool(t *testing.T) {
+	if err := e.Backend.(*backend).tidyPools(e.Context, e.Storage); err != nil {
+		t.Fatal(err)
+	}
+	members := e.poolMembers(t)
+	if len(members) != 2 {
+		t.Fatalf("expected 2 pool members but received %d", len(members))
+	}
+	for _, member := range members {
+		if member.RoleVersion != 1 || len(member.InlinePolicies) != 2 || len(member.RemotePolicies) != 1 {
+			t.Fatalf("unexpected pool member %+v", member)
+		}
+	}
+}
+
+// ReadPooledCreds
+func (e *testEnv) ReadPooledCreds(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/pooled",
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	userName := resp.Secret.InternalData["username"].(string)
+	if !strings.HasPrefix(userName, poolDisplayName+"-pooled-") {
+		t.Fatalf("expected a pooled user but received %s", userName)
+	}
+	if members := e.poolMembers(t); len(members) != 1 {
+		t.Fatalf("expected 1 pool member left but received %d", len(members))
+	}
+	e.MostRecentSecret = resp.Secret
+}
+
+// ReplaceStalePoolMembers
+func (e *testEnv) ReplaceStalePoolMembers(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/pooled",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"pool_size": 3,
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if err := e.Backend.(*backend).tidyPools(e.Context, e.Storage); err != nil {
+		t.Fatal(err)
+	}
+	members := e.poolMembers(t)
+	if len(members) != 3 {
+		t.Fatalf("expected 3 pool members but received %d", len(members))
+	}
+	for _, member := range members {
+		if member.RoleVersion != 2 {
+			t.Fatalf("expected only members of role version 2 but received %+v", member)
+		}
+	}
+
+	req.Operation = logical.DeleteOperation
+	req.Data = nil
+	if resp, err := e.Backend.HandleRequest(e.Context, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if err := e.Backend.(*backend).tidyPools(e.Context, e.Storage); err != nil {
+		t.Fatal(err)
+	}
+	if members := e.poolMembers(t); len(members) != 0 {
+		t.Fatalf("expected the pool of a deleted role to be emptied but received %d members", len(members))
+	}
+}
+
+func (e *testEnv) poolMembers(t *testing.T) []*poolMember {
+	names, err := e.Storage.List(e.Context, poolPath+"pooled/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := make([]*poolMember, 0, len(names))
+	for _, name := range names {
+		member, err := readPoolMember(e.Context, e.Storage, poolPath+"pooled/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+// EnforceLeaseLimits
+func (e *testEnv) EnforceLeaseLimits(t *testing.T) {
+	handle := func(req *logical.Request) (*logical.Response, error) {
+		req.Storage = e.Storage
+		return e.Backend.HandleRequest(e.Context, req)
+	}
+	mustSucceed := func(req *logical.Request) *logical.Response {
+		resp, err := handle(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+		}
+		return resp
+	}
+	mustFail := func(req *logical.Request, reason string) {
+		resp, err := handle(req)
+		if err != nil {
+			t.Fatalf("expected an error response about %s but received %s", reason, err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected the request to fail on %s", reason)
+		}
+		if err = resp.Error(); !strings.Contains(err.Error(), reason) {
+			t.Fatalf("expected an error about %s but received %s", reason, err)
+		}
+	}
+	readCreds := func() *logical.Request {
+		return &logical.Request{Operation: logical.ReadOperation, Path: "creds/limited"}
+	}
+	revoke := func(secret *logical.Secret) {
+		mustSucceed(&logical.Request{Operation: logical.RevokeOperation, Secret: secret})
+	}
+
+	// Nothing is counted while neither the mount nor the role has limits.
+	mustSucceed(&logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/unlimited",
+		Data: map[string]interface{}{
+			"inline_policies": policyDocument,
+		},
+	})
+	unlimited := mustSucceed(&logical.Request{Operation: logical.ReadOperation, Path: "creds/unlimited"})
+	if _, ok := unlimited.Secret.InternalData[leaseCountedKey]; ok {
+		t.Fatal("expected a lease without limits not to be counted")
+	}
+	if keys, err := e.Storage.List(e.Context, "lease_count/"); err != nil || len(keys) != 0 {
+		t.Fatalf("expected no lease counts but received %v, %v", keys, err)
+	}
+	revoke(unlimited.Secret)
+
+	mustSucceed(&logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/limited",
+		Data: map[string]interface{}{
+			"inline_policies":       policyDocument,
+			"max_concurrent_leases": 1,
+		},
+	})
+	if data := e.readRoleData(t, "limited"); data["max_concurrent_leases"] != 1 || data["issue_rate_limit"] != 0 {
+		t.Fatalf("unexpected limits %v and %v", data["max_concurrent_leases"], data["issue_rate_limit"])
+	}
+	first := mustSucceed(readCreds())
+	mustFail(readCreds(), "max_concurrent_leases")
+	// Revoking a lease issued before leases were counted leaves the count alone.
+	uncounted := make(map[string]interface{}, len(first.Secret.InternalData))
+	for k, v := range first.Secret.InternalData {
+		uncounted[k] = v
+	}
+	delete(uncounted, leaseCountedKey)
+	revoke(&logical.Secret{InternalData: uncounted})
+	mustFail(readCreds(), "max_concurrent_leases")
+	revoke(first.Secret)
+	second := mustSucceed(readCreds())
+	revoke(second.Secret)
+
+	// A count left behind, e.g. by a release that failed, is reconciled with
+	// the issued index.
+	if err := writeLeaseCount(e.Context, e.Storage, leaseCountRolePath+"limited", &leaseCount{Active: 1}); err != nil {
+		t.Fatal(err)
+	}
+	mustFail(readCreds(), "max_concurrent_leases")
+	if err := e.Backend.(*backend).recountLeases(e.Context, e.Storage); err != nil {
+		t.Fatal(err)
+	}
+	recounted := mustSucceed(readCreds())
+	if err := e.Backend.(*backend).recountLeases(e.Context, e.Storage); err != nil {
+		t.Fatal(err)
+	}
+	mustFail(readCreds(), "max_concurrent_leases")
+	revoke(recounted.Secret)
+
+	mustSucceed(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/limits",
+		Data: map[string]interface{}{
+			"issue_rate_limit": 1,
+		},
+	})
+	third := mustSucceed(readCreds())
+	resp := mustSucceed(&logical.Request{Operation: logical.ReadOperation, Path: "config/limits"})
+	if resp.Data["issue_rate_limit"] != 1 || resp.Data["active_leases"] != 1 {
+		t.Fatalf("unexpected limits %v", resp.Data)
+	}
+	revoke(third.Secret)
+	mustFail(readCreds(), "issue_rate_limit")
+}
+
+// ReadIssued
+func (e *testEnv) ReadIssued(t *testing.T) {
+	secretId := e.MostRecentSecret.InternalData["secret_id"].(string)
+	req := &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "issued/",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"role": "policy-based",
+		},
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	keys := resp.Data["keys"].([]string)
+	if len(keys) != 1 || keys[0] != secretId {
+		t.Fatalf("expected only %s but received %v", secretId, keys)
+	}
+	req.Data["role"] = "other"
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if len(resp.Data) != 0 {
+		t.Fatalf("expected no credentials for another role but received %v", resp.Data)
+	}
+
+	req = &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "issued/" + secretId,
+		Storage:   e.Storage,
+	}
+	resp, err = e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp == nil {
+		t.Fatal("expected the issued credential to be indexed")
+	}
+	if resp.Data["role_name"] != "policy-based" || resp.Data["role_type"] != "cam" {
+		t.Fatalf("unexpected role of the issued credential: %v", resp.Data)
+	}
+	if resp.Data["username"] != e.MostRecentSecret.InternalData["username"] || resp.Data["uin"] != "100000546533" {
+		t.Fatalf("unexpected user of the issued credential: %v", resp.Data)
+	}
+	// Two inline policies and three remote ones.
+	if policyIds := resp.Data["policy_ids"].([]uint64); len(policyIds) != 5 {
+		t.Fatalf("expected 5 policy ids but received %v", policyIds)
+	}
+	if expiration := resp.Data["expiration"].(time.Time); !expiration.After(time.Now()) {
+		t.Fatalf("expected the expiration to be in the future but received %s", expiration)
+	}
+}
+
+// ReadRevokedIssued
+func (e *testEnv) ReadRevokedIssued(t *testing.T) {
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "issued/" + e.MostRecentSecret.InternalData["secret_id"].(string),
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if resp != nil {
+		t.Fatalf("expected revoked credentials to be removed from the index but received %v", resp.Data)
+	}
+}
+
+// CleanupUser
+func (e *testEnv) CleanupUser(t *testing.T) {
+	cleanup := func(data map[string]interface{}) *logical.Response {
+		req := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "cleanup/user",
+			Storage:   e.Storage,
+			Data:      data,
+		}
+		resp, err := e.Backend.HandleRequest(e.Context, req)
+		if err != nil {
+			t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+		}
+		return resp
+	}
+
+	if resp := cleanup(map[string]interface{}{"username": "missing-user"}); resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a missing user but received %#v", resp)
+	}
+
+	// admin was not created by this backend.
+	if resp := cleanup(map[string]interface{}{"uin": "100000000777"}); resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a user not issued by this backend but received %#v", resp)
+	}
+	// Nor was a user whose name only looks generated.
+	lookalike := &camUser{Name: "token-policy-based-1700000000-1234", Uin: 100000000778}
+	if managed, err := e.Backend.(*backend).isManagedUser(e.Context, e.Storage, "", lookalike); err != nil || managed {
+		t.Fatalf("expected %s not to be managed but received %t, %v", lookalike.Name, managed, err)
+	}
+	resp := cleanup(map[string]interface{}{"uin": "100000000777", "force": true})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v", resp)
+	}
+	if deleted := resp.Data["deleted_policy_ids"].([]uint64); len(deleted) != 0 {
+		t.Fatalf("expected no policies of admin to be deleted but received %v", deleted)
+	}
+
+	resp = cleanup(map[string]interface{}{"uin": e.MostRecentSecret.InternalData["uin"]})
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v", resp)
+	}
+	if resp.Data["username"] != "test124" {
+		t.Fatalf("expected username test124 but received %v", resp.Data["username"])
+	}
+	if keys := resp.Data["access_key_ids"].([]string); len(keys) != 1 || keys[0] != "ABBD8GFED7sSr33rSq9KK7h5ISSEoQrFXkmb" {
+		t.Fatalf("unexpected access_key_ids %v", keys)
+	}
+	if detached := resp.Data["detached_policy_ids"].([]uint64); len(detached) != 2 {
+		t.Fatalf("expected 2 detached policies but received %v", detached)
+	}
+	if deleted := resp.Data["deleted_policy_ids"].([]uint64); len(deleted) != 1 || deleted[0] != 17698703 {
+		t.Fatalf("expected only the user's own policy to be deleted but received %v", deleted)
+	}
+	if groups := resp.Data["removed_group_ids"].([]uint64); len(groups) != 1 || groups[0] != 2004 {
+		t.Fatalf("unexpected removed_group_ids %v", groups)
+	}
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "issued/" + e.MostRecentSecret.InternalData["secret_id"].(string),
+		Storage:   e.Storage,
+	}
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	if revokedAt := resp.Data["revoked_at"].(time.Time); revokedAt.IsZero() {
+		t.Fatal("expected the credential to be marked as revoked")
+	}
+}
+
+// LookupAndRevokeIssuedSTS
+func (e *testEnv) LookupAndRevokeIssuedSTS(t *testing.T) {
+	resp, err := e.lookupAndRevokeSTS()
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+	}
+	credentials := resp.Data["credentials"].([]map[string]interface{})
+	if len(credentials) != 1 || credentials[0]["revoked_at"].(time.Time).IsZero() {
+		t.Fatalf("expected the credential to be marked as revoked but received %v", credentials)
+	}
+}
+
+// LookupAndRevokeUnrevokableIssuedSTS
+func (e *testEnv) LookupAndRevokeUnrevokableIssuedSTS(t *testing.T) {
+	resp, err := e.lookupAndRevokeSTS()
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected an error revoking STS credentials of a role without sts_revoke_sessions")
+	}
+	if err != nil && !strings.Contains(err.Error(), "can't be revoked") {
+		t.Fatalf("expected an error explaining why but received %v", err)
+	}
+}
+
+func (e *testEnv) lookupAndRevokeSTS() (*logical.Response, error) {
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "lookup",
+		Storage:   e.Storage,
+		Data: map[string]interface{}{
+			"access_key_id": "AKID65zyIP0mpXtaI******WIQVMn1umNH58",
+			"revoke":        true,
+		},
+	}
+	return e.Backend.HandleRequest(e.Context, req)
+}
+
+// LookupAndRevokeIssued
+func (e *testEnv) LookupAndRevokeIssued(t *testing.T) {
+	secretId := e.MostRecentSecret.InternalData["secret_id"].(string)
+	uin := e.MostRecentSecret.InternalData["uin"].(string)
+	lookup := func(data map[string]interface{}) []map[string]interface{} {
+		req := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "lookup",
+			Storage:   e.Storage,
+			Data:      data,
+		}
+		resp, err := e.Backend.HandleRequest(e.Context, req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: resp: %#v\nerr:%v", resp, err)
+		}
+		if resp == nil {
+			return nil
+		}
+		return resp.Data["credentials"].([]map[string]interface{})
+	}
+
+	credentials := lookup(map[string]interface{}{"access_key_id": secretId})
+	if len(credentials) != 1 || credentials[0]["role_name"] != "policy-based" ||
+		credentials[0]["lease_path"] != "creds/policy-based" {
+		t.Fatalf("unexpected credentials %v", credentials)
+	}
+	if credentials := lookup(map[string]interface{}{"access_key_id": "AKIDunknown"}); credentials != nil {
+		t.Fatalf("expected no credentials for an unknown key but received %v", credentials)
+	}
+
+	credentials = lookup(map[string]interface{}{"uin": uin, "revoke": true})
+	if len(credentials) != 1 || credentials[0]["secret_id"] != secretId {
+		t.Fatalf("unexpected credentials %v", credentials)
+	}
+	if revokedAt := credentials[0]["revoked_at"].(time.Time); revokedAt.IsZero() {
+		t.Fatal("expected the credential to be marked as revoked")
+	}
+}
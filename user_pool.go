@@ -0,0 +1,232 @@
+package tencentcloud
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	poolPath = "pool/"
+
+	// maxPoolSize bounds pool_size, every pool member being a CAM user kept
+	// around between reads.
+	maxPoolSize = 50
+
+	// poolDisplayName stands in for the display name of the requester in the
+	// name of pre-created users.
+	poolDisplayName = "pool"
+)
+
+// poolMember is a CAM user created ahead of time with a role's policies
+// attached, waiting for a read of creds to issue it an access key.
+type poolMember struct {
+	UserName       string          `json:"username"`
+	Uin            uint64          `json:"uin"`
+	Account        string          `json:"account"`
+	RoleVersion    int             `json:"role_version"`
+	InlinePolicies []*remotePolicy `json:"inline_policies"`
+	RemotePolicies []*remotePolicy `json:"remote_policies"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// current reports whether the member was created from this version of the role.
+func (m *poolMember) current(role *roleEntry) bool {
+	return role != nil && m.RoleVersion == role.Version && m.Account == role.Account
+}
+
+// pushFails records the steps that created the member, so a failed read of
+// creds deletes it the same way as a user created for that read.
+func (m *poolMember) pushFails(failList *list.List) {
+	failList.PushBack(&addUserFail{&m.UserName})
+	for _, policy := range m.InlinePolicies {
+		failList.PushBack(&createPolicyFail{&policy.PolicyId})
+		failList.PushBack(&attachUserPolicyFail{&policy.PolicyId, &m.Uin})
+	}
+	for _, policy := range m.RemotePolicies {
+		failList.PushBack(&attachUserPolicyFail{&policy.PolicyId, &m.Uin})
+	}
+}
+
+// claimPoolMember takes a current member out of the role's pool. It returns
+// nil if the role has no pool or the pool is empty.
+func (b *backend) claimPoolMember(ctx context.Context, s logical.Storage,
+	roleName string, role *roleEntry) (*poolMember, error) {
+	if role.PoolSize == 0 {
+		return nil, nil
+	}
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+	names, err := s.List(ctx, poolPath+roleName+"/")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		key := poolPath + roleName + "/" + name
+		member, err := readPoolMember(ctx, s, key)
+		if err != nil {
+			return nil, err
+		}
+		// Stale members are left for tidyPools to delete.
+		if member == nil || !member.current(role) {
+			continue
+		}
+		if err := s.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+		return member, nil
+	}
+	return nil, nil
+}
+
+// tidyPools deletes pool members of deleted roles, of older role versions and
+// beyond pool_size, and then fills every pool back up to its pool_size.
+func (b *backend) tidyPools(ctx context.Context, s logical.Storage) error {
+	apiErrs := &multierror.Error{}
+
+	pooledRoles, err := s.List(ctx, poolPath)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]int)
+	for _, pooledRole := range pooledRoles {
+		roleName := strings.TrimSuffix(pooledRole, "/")
+		role, err := readRole(ctx, s, roleName)
+		if err != nil {
+			return err
+		}
+		names, err := s.List(ctx, poolPath+pooledRole)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			key := poolPath + pooledRole + name
+			member, err := b.takeStalePoolMember(ctx, s, key, role, current[roleName])
+			if err != nil {
+				return err
+			}
+			if member == nil {
+				current[roleName]++
+				continue
+			}
+			if err := b.deletePoolMember(ctx, s, key, member); err != nil {
+				apiErrs = multierror.Append(apiErrs, err)
+			}
+		}
+	}
+
+	roleNames, err := s.List(ctx, rolePath)
+	if err != nil {
+		return err
+	}
+	for _, roleName := range roleNames {
+		role, err := readRole(ctx, s, roleName)
+		if err != nil {
+			return err
+		}
+		if role == nil || role.PoolSize == 0 {
+			continue
+		}
+		for i := current[roleName]; i < role.PoolSize; i++ {
+			if err := b.addPoolMember(ctx, s, roleName, role); err != nil {
+				apiErrs = multierror.Append(apiErrs, fmt.Errorf("unable to fill pool of role %s: %w", roleName, err))
+				break
+			}
+		}
+	}
+	return apiErrs.ErrorOrNil()
+}
+
+// takeStalePoolMember removes the member from storage and returns it if it
+// is no longer needed, so it can't be claimed while its user is deleted.
+// kept is the number of current members of the role already kept.
+func (b *backend) takeStalePoolMember(ctx context.Context, s logical.Storage, key string,
+	role *roleEntry, kept int) (*poolMember, error) {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+	member, err := readPoolMember(ctx, s, key)
+	if err != nil || member == nil {
+		return nil, err
+	}
+	if member.current(role) && kept < role.PoolSize {
+		return nil, nil
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// deletePoolMember deletes the user of a member taken out of its pool. If that
+// fails the member is put back so the next tidy tries again.
+func (b *backend) deletePoolMember(ctx context.Context, s logical.Storage, key string, member *poolMember) error {
+	client, err := b.accountCAMClient(ctx, s, member.Account)
+	if err == nil {
+		err = deleteCAMUser(client, member.UserName, member.Uin, member.InlinePolicies, member.RemotePolicies)
+	}
+	if err == nil {
+		return nil
+	}
+	if putErr := writePoolMember(ctx, s, key, member); putErr != nil {
+		return putErr
+	}
+	return fmt.Errorf("unable to delete pooled user %s: %w", member.UserName, err)
+}
+
+// addPoolMember creates a user with the role's policies attached and adds it
+// to the role's pool.
+func (b *backend) addPoolMember(ctx context.Context, s logical.Storage, roleName string, role *roleEntry) error {
+	client, err := b.accountCAMClient(ctx, s, role.Account)
+	if err != nil {
+		return err
+	}
+	failList := list.New()
+	userName := generateUsername(poolDisplayName, roleName)
+	createUserResp, inlinePolicies, remotePolicies, err := createPolicyUser(userName, role, failList, client)
+	if err != nil {
+		rollback(failList, client, b)
+		return err
+	}
+	member := &poolMember{
+		UserName:       userName,
+		Uin:            *(createUserResp.Response.Uin),
+		Account:        role.Account,
+		RoleVersion:    role.Version,
+		InlinePolicies: inlinePolicies,
+		RemotePolicies: remotePolicies,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := writePoolMember(ctx, s, poolPath+roleName+"/"+userName, member); err != nil {
+		rollback(failList, client, b)
+		return err
+	}
+	return nil
+}
+
+func readPoolMember(ctx context.Context, s logical.Storage, key string) (*poolMember, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	member := &poolMember{}
+	if err := entry.DecodeJSON(member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func writePoolMember(ctx context.Context, s logical.Storage, key string, member *poolMember) error {
+	entry, err := logical.StorageEntryJSON(key, member)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}